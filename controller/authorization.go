@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"main/util"
+	"net/http"
+)
+
+var errForbidden = errors.New("forbidden")
+
+// authorize reports whether the caller owns at least one of the accounts, or is an admin or a service.
+// Otherwise, it writes 403, or the account API error if ownership can't be resolved.
+func (receiver TransactionController) authorize(ctx *gin.Context, accountIDs ...string) bool {
+	if util.IsPrivileged(ctx) {
+		return true
+	}
+
+	for _, accountID := range accountIDs {
+		owned, err := receiver.Ownership.Owns(accountID, ctx)
+		if err != nil {
+			accountFailure(ctx, http.StatusNotFound, err)
+			return false
+		}
+
+		if owned {
+			return true
+		}
+	}
+
+	forbidden(ctx)
+	return false
+}
+
+func forbidden(ctx *gin.Context) {
+	fail(ctx, http.StatusForbidden, errForbidden)
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"main/db"
+	"main/fx"
+	"main/model"
+	"main/request"
+	"main/response"
+	"main/util"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errInvalidRecipient = errors.New("invalid recipient")
+
+// errorCodes maps known errors to their response codes. Other errors get the generic code of their status.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{db.ErrNotFound, response.CodeTransactionNotFound},
+	{db.ErrTypeNotFound, response.CodeTypeNotFound},
+	{db.ErrTypeExists, response.CodeTypeExists},
+	{db.ErrTypeInactive, response.CodeTypeInactive},
+	{db.ErrInsufficientFunds, response.CodeInsufficientFunds},
+	{db.ErrRefundExceeded, response.CodeRefundExceeded},
+	{db.ErrReversalOfReversal, response.CodeReversalOfReversal},
+	{db.ErrNotSettled, response.CodeNotSettled},
+	{db.ErrIllegalTransition, response.CodeIllegalTransition},
+	{db.ErrNotFinal, response.CodeNotFinal},
+	{errIdempotencyKeyReused, response.CodeIdempotencyKeyReused},
+	{errInvalidRecipient, response.CodeInvalidRecipient},
+	{errForbidden, response.CodeForbidden},
+	{model.ErrInvalidCursor, response.CodeInvalidCursor},
+	{fx.ErrRateNotFound, response.CodeRateNotFound},
+	{util.ErrAccountNotFound, response.CodeAccountNotFound},
+	{util.ErrAccountUnavailable, response.CodeAccountUnavailable},
+}
+
+// fail records err on the context and writes it with status and its error code.
+func fail(ctx *gin.Context, status int, err error) {
+	_ = ctx.Error(err)
+
+	body := response.ErrorResponse{Error: err.Error()}
+	for _, value := range errorCodes {
+		if errors.Is(err, value.err) {
+			body.Code = value.code
+			break
+		}
+	}
+	response.Send(ctx, status, body)
+}
+
+// accountFailure writes an error from the account API: notFound if the account doesn't exist, 403 if
+// the caller may not read it, 503 with a Retry-After header while the account API is unavailable, and
+// 502 otherwise.
+func accountFailure(ctx *gin.Context, notFound int, err error) {
+	var accErr *util.AccountError
+	switch {
+	case errors.Is(err, util.ErrAccountNotFound):
+		fail(ctx, notFound, err)
+	case errors.As(err, &accErr) && (accErr.StatusCode == http.StatusForbidden ||
+		accErr.StatusCode == http.StatusUnauthorized):
+		forbidden(ctx)
+	case errors.Is(err, util.ErrAccountUnavailable):
+		ctx.Header("Retry-After", strconv.Itoa(int(util.AccountRetryAfter.Seconds())))
+		fail(ctx, http.StatusServiceUnavailable, err)
+	default:
+		fail(ctx, http.StatusBadGateway, err)
+	}
+}
+
+// bind decodes and validates the JSON body into req. It writes 400 with all field errors and returns
+// false if that fails.
+func bind(ctx *gin.Context, req any) bool {
+	err := ctx.ShouldBindJSON(req)
+	if err == nil {
+		return true
+	}
+	_ = ctx.Error(err)
+
+	fields := request.FieldErrors(err)
+	if len(fields) == 0 {
+		response.Send(ctx, http.StatusBadRequest, response.ErrorResponse{Error: err.Error(),
+			Code: response.CodeInvalidRequest})
+		return false
+	}
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field.Field + " " + field.Message
+	}
+	response.Send(ctx, http.StatusBadRequest, response.ErrorResponse{
+		Error:  "invalid request: " + strings.Join(messages, ", "),
+		Code:   response.CodeValidationFailed,
+		Fields: fields,
+	})
+	return false
+}
+
+// invalidAmount writes 400 for an amount with more decimal places than currency uses.
+func invalidAmount(ctx *gin.Context, currency model.Currency) {
+	message := fmt.Sprintf("must have at most %d decimal places in %s", currency.Places(), currency)
+	_ = ctx.Error(fmt.Errorf("%w: %s", model.ErrInvalidAmount, message))
+
+	response.Send(ctx, http.StatusBadRequest, response.ErrorResponse{
+		Error:  "invalid request: amount " + message,
+		Code:   response.CodeValidationFailed,
+		Fields: []response.FieldError{{Field: "amount", Code: response.FieldInvalid, Message: message}},
+	})
+}
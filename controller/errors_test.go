@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"main/response"
+	"main/util"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccountFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		code       string
+		retryAfter string
+	}{
+		{"not found", util.ErrAccountNotFound, http.StatusNotFound, response.CodeAccountNotFound, ""},
+		{"forbidden", &util.AccountError{StatusCode: http.StatusForbidden}, http.StatusForbidden,
+			response.CodeForbidden, ""},
+		{"unavailable", util.ErrAccountUnavailable, http.StatusServiceUnavailable,
+			response.CodeAccountUnavailable, "30"},
+		{"upstream", errors.New("connection reset"), http.StatusBadGateway, response.CodeUpstream, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/transaction", nil)
+
+			accountFailure(ctx, http.StatusNotFound, test.err)
+
+			if recorder.Code != test.status {
+				t.Errorf("status = %d, want %d", recorder.Code, test.status)
+			}
+			if got := recorder.Header().Get("Retry-After"); got != test.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, test.retryAfter)
+			}
+
+			var body response.ErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("json.Unmarshal() error: %v", err)
+			}
+			if body.Code != test.code {
+				t.Errorf("code = %q, want %q", body.Code, test.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"main/messaging"
+	"main/response"
+	"net/http"
+	"time"
+)
+
+type HealthController struct {
+	DB        *sql.DB
+	Messaging *messaging.Messaging
+}
+
+//	@description	Get service health. Responds with 503 when the database can't be reached.
+//	@summary		Get service health
+//	@produce		json
+//	@tags			health
+//	@success		200	{object}	response.HealthResponse
+//	@failure		503	{object}	response.HealthResponse
+//	@router			/health [GET]
+func (receiver HealthController) Get(ctx *gin.Context) {
+	health := response.HealthResponse{
+		Status:      "ok",
+		Database:    "up",
+		Messaging:   "connected",
+		DroppedLogs: receiver.Messaging.Dropped(),
+	}
+	status := http.StatusOK
+
+	if !receiver.Messaging.Connected() {
+		health.Status = "degraded"
+		health.Messaging = "disconnected"
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := receiver.DB.PingContext(pingCtx); err != nil {
+		_ = ctx.Error(err)
+		health.Status = "unavailable"
+		health.Database = "down"
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, health)
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"main/util"
+	"net/http"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+var errIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+func requestHash(req any) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replay writes the response stored for the caller's key, or 409 if the caller used key with a different
+// request. It returns false if there is nothing stored for key and the request should be processed.
+func (receiver TransactionController) replay(key, hash string, ctx *gin.Context) bool {
+	stored, found, err := receiver.DB.GetIdempotencyKey(util.Subject(ctx), key, ctx)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
+		return true
+	}
+
+	if !found {
+		return false
+	}
+
+	if stored.RequestHash != hash {
+		fail(ctx, http.StatusConflict, errIdempotencyKeyReused)
+		return true
+	}
+
+	ctx.Header("Idempotent-Replayed", "true")
+	ctx.Data(stored.StatusCode, "application/json; charset=utf-8", stored.Response)
+	return true
+}
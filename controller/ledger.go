@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"main/util"
+	"net/http"
+)
+
+//	@description	Get account balance derived from the ledger postings of this service, one per currency the account has postings in.
+//	@summary		Get account balance derived from the ledger
+//	@accept			json
+//	@produce		json
+//	@tags			ledger
+//	@param			accountID	path		string	true	"Account ID"
+//	@success		200			{array}		model.Balance
+//	@failure		400			{object}	response.ErrorResponse
+//	@failure		403			{object}	response.ErrorResponse
+//	@failure		500			{object}	response.ErrorResponse
+//	@security		JWT
+//	@param			Authorization	header	string	true	"Authorization"
+//	@router			/balance/{accountID} [GET]
+func (receiver TransactionController) GetBalance(ctx *gin.Context) {
+	accountID := ctx.Param("accountID")
+
+	if !util.IsValidUUID(accountID) {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid account id"))
+		return
+	}
+
+	if !receiver.authorize(ctx, accountID) {
+		return
+	}
+
+	balances, err := receiver.DB.GetBalance(accountID, ctx)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, balances)
+}
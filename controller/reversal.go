@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"main/db"
+	"main/request"
+	"main/util"
+	"net/http"
+)
+
+//	@description	Reverse a transaction, fully or partially, with a linked compensating transaction.
+//	@summary		Reverse a transaction
+//	@accept			json
+//	@produce		json
+//	@tags			transaction
+//	@param			transactionID	path		string					true	"Transaction ID"
+//	@param			requestBody		body		request.ReversalRequest	false	"Refund data"
+//	@success		201				{object}	model.Transaction
+//	@failure		400				{object}	response.ErrorResponse
+//	@failure		403				{object}	response.ErrorResponse
+//	@failure		404				{object}	response.ErrorResponse
+//	@failure		500				{object}	response.ErrorResponse
+//	@failure		502				{object}	response.ErrorResponse
+//	@failure		503				{object}	response.ErrorResponse
+//	@security		JWT
+//	@param			Authorization	header	string	true	"Authorization"
+//	@router			/transaction/{transactionID}/reverse [POST]
+func (receiver TransactionController) Reverse(ctx *gin.Context) {
+	transactionID := ctx.Param("transactionID")
+
+	if !util.IsValidUUID(transactionID) {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid transaction id"))
+		return
+	}
+
+	// The body is optional, without it the whole remaining amount is reversed.
+	var req request.ReversalRequest
+	if ctx.Request.ContentLength != 0 && !bind(ctx, &req) {
+		return
+	}
+
+	if req.Amount < 0 {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid amount, can't be negative"))
+		return
+	}
+
+	original, err := receiver.DB.Get(transactionID, ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		fail(ctx, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !receiver.authorize(ctx, original.RecipientID) {
+		return
+	}
+
+	if !req.Amount.ValidFor(original.Currency) {
+		invalidAmount(ctx, original.Currency)
+		return
+	}
+
+	payer, err := receiver.Accounts.Get(original.RecipientID, ctx)
+	if err != nil {
+		accountFailure(ctx, http.StatusNotFound, err)
+		return
+	}
+
+	reversal, err := receiver.DB.Reverse(transactionID, req.Amount, payer, util.Subject(ctx), ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		fail(ctx, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, db.ErrRefundExceeded) || errors.Is(err, db.ErrReversalOfReversal) ||
+		errors.Is(err, db.ErrNotSettled) || errors.Is(err, db.ErrInsufficientFunds) {
+		fail(ctx, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, reversal)
+}
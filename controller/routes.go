@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"main/util"
+)
+
+// Register adds the API routes to api, each behind the scope it requires. Type management and
+// deletions require the admin scope. The token must already be verified by util.ValidateToken.
+func (receiver TransactionController) Register(api gin.IRoutes) {
+	read := util.RequireScope(util.ScopeRead)
+	write := util.RequireScope(util.ScopeWrite)
+	admin := util.RequireScope(util.ScopeAdmin)
+
+	api.POST("/transaction", write, receiver.Create)
+	api.POST("/transaction/:transactionID/reverse", write, receiver.Reverse)
+	api.POST("/transaction/:transactionID/cancel", write, receiver.Cancel)
+	api.PATCH("/transaction/:transactionID/status", write, receiver.UpdateStatus)
+
+	api.GET("/types", read, receiver.GetTypes)
+	api.POST("/types", admin, receiver.CreateType)
+	api.PATCH("/types/:typeID", admin, receiver.RenameType)
+	api.DELETE("/types/:typeID", admin, receiver.DeactivateType)
+	api.GET("/transaction/id/:transactionID", read, receiver.GetByID)
+	api.GET("/transaction/:accountID/:type", read, receiver.GetAll)
+	api.GET("/balance/:accountID", read, receiver.GetBalance)
+
+	api.DELETE("/transaction/:transactionID", admin, receiver.Delete)
+	api.DELETE("/transactions/:accountID", admin, receiver.DeleteForAccount)
+
+	api.GET("/transactions/deleted/:accountID", admin, receiver.GetDeleted)
+}
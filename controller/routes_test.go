@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"main/model"
+	"main/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret    = "routes-test-secret"
+	testSubject   = "495d45e9-644c-40b8-94e8-103cad128331"
+	testSender    = "5d84ca00-c079-4577-9560-e1014086affe"
+	testRecipient = "8cca0453-8e84-4f3b-aa40-7fc9cd162a34"
+	testID        = "4a5ed2e0-5cdb-4f9e-96e3-ecc372ba4f0c"
+)
+
+// stubStore answers every call with a fixed, successful result. The transaction is authorized and
+// moves money between two accounts of the test subject.
+type stubStore struct{}
+
+func (receiver stubStore) transaction() model.Transaction {
+	return model.Transaction{ID: testID, SenderID: testSender, RecipientID: testRecipient, Amount: 100,
+		Currency: "EUR", RecipientAmount: 100, RecipientCurrency: "EUR", Rate: model.RateOne,
+		Type: receiver.transactionType(), Status: model.Authorized}
+}
+
+func (receiver stubStore) transactionType() model.TransactionType {
+	return model.TransactionType{ID: 1, Type: "transfer", Active: true}
+}
+
+func (receiver stubStore) Create(model.Transaction, model.Account, *model.IdempotencyKey, *gin.Context) error {
+	return nil
+}
+
+func (receiver stubStore) Get(string, *gin.Context) (model.Transaction, error) {
+	return receiver.transaction(), nil
+}
+
+func (receiver stubStore) GetAll(string, string, bool, model.TransactionFilter, *gin.Context) (
+	[]model.Transaction, string, error) {
+	return []model.Transaction{receiver.transaction()}, "", nil
+}
+
+func (receiver stubStore) Reverse(string, model.Amount, model.Account, string, *gin.Context) (
+	model.Transaction, error) {
+	return receiver.transaction(), nil
+}
+
+func (receiver stubStore) Transition(string, model.StatusChange, *gin.Context) error {
+	return nil
+}
+
+func (receiver stubStore) Delete(string, model.Deletion, *gin.Context) error {
+	return nil
+}
+
+func (receiver stubStore) DeleteForAccount(string, model.Deletion, *gin.Context) error {
+	return nil
+}
+
+func (receiver stubStore) GetBalance(string, *gin.Context) ([]model.Balance, error) {
+	return []model.Balance{}, nil
+}
+
+func (receiver stubStore) GetIdempotencyKey(string, string, *gin.Context) (model.IdempotencyKey, bool, error) {
+	return model.IdempotencyKey{}, false, nil
+}
+
+func (receiver stubStore) GetType(int, *gin.Context) (model.TransactionType, error) {
+	return receiver.transactionType(), nil
+}
+
+func (receiver stubStore) GetTypes(*gin.Context) ([]model.TransactionType, error) {
+	return []model.TransactionType{receiver.transactionType()}, nil
+}
+
+func (receiver stubStore) CreateType(string, bool, *gin.Context) (model.TransactionType, error) {
+	return receiver.transactionType(), nil
+}
+
+func (receiver stubStore) RenameType(int, string, *gin.Context) (model.TransactionType, error) {
+	return receiver.transactionType(), nil
+}
+
+func (receiver stubStore) DeactivateType(int, *gin.Context) (model.TransactionType, error) {
+	return receiver.transactionType(), nil
+}
+
+// testAccountAPI serves every account as an open EUR account of the test subject.
+func testAccountAPI(t *testing.T) *util.AccountClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(writer).Encode(map[string]any{
+			"pk":         strings.TrimPrefix(req.URL.Path, "/api/v1/account/"),
+			"customerID": testSubject,
+			"amount":     1000,
+			"currency":   "EUR",
+			"limit":      0,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return util.NewAccountClient(server.URL, "", time.Second)
+}
+
+func testRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	accounts := testAccountAPI(t)
+	api := router.Group("api/v1").Use(util.ValidateToken)
+	TransactionController{
+		DB:        stubStore{},
+		Accounts:  accounts,
+		Ownership: util.NewOwnership(accounts, time.Minute),
+	}.Register(api)
+	return router
+}
+
+func testToken(t *testing.T, scopes []string) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"sub": testSubject,
+		"iat": time.Now().Add(-time.Minute).Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if scopes != nil {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("SignedString() error: %v", err)
+	}
+	return "Bearer " + token
+}
+
+func TestRouteScopes(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("JWT_AUDIENCE", "")
+
+	transfer := `{"senderAccountID":"` + testSender + `","recipientID":"` + testSubject +
+		`","recipientAccountID":"` + testRecipient + `","amount":1.00,"type":1}`
+
+	// status is what a caller with the required scope gets. Customers own both accounts, so they get past
+	// the ownership checks as well.
+	routes := []struct {
+		method string
+		path   string
+		body   string
+		scope  string
+		status int
+	}{
+		{http.MethodPost, "/api/v1/transaction", transfer, util.ScopeWrite, http.StatusCreated},
+		{http.MethodPost, "/api/v1/transaction/" + testID + "/reverse", "", util.ScopeWrite, http.StatusCreated},
+		{http.MethodPost, "/api/v1/transaction/" + testID + "/cancel", "", util.ScopeWrite, http.StatusOK},
+		{http.MethodPatch, "/api/v1/transaction/" + testID + "/status", `{"status":"settled"}`, util.ScopeWrite,
+			http.StatusOK},
+		{http.MethodGet, "/api/v1/types", "", util.ScopeRead, http.StatusOK},
+		{http.MethodPost, "/api/v1/types", `{"type":"standing-order"}`, util.ScopeAdmin, http.StatusCreated},
+		{http.MethodPatch, "/api/v1/types/1", `{"type":"standing-order"}`, util.ScopeAdmin, http.StatusOK},
+		{http.MethodDelete, "/api/v1/types/1", "", util.ScopeAdmin, http.StatusOK},
+		{http.MethodGet, "/api/v1/transaction/id/" + testID, "", util.ScopeRead, http.StatusOK},
+		{http.MethodGet, "/api/v1/transaction/" + testSender + "/all", "", util.ScopeRead, http.StatusOK},
+		{http.MethodGet, "/api/v1/balance/" + testSender, "", util.ScopeRead, http.StatusOK},
+		{http.MethodDelete, "/api/v1/transaction/" + testID, "", util.ScopeAdmin, http.StatusNoContent},
+		{http.MethodDelete, "/api/v1/transactions/" + testSender, "", util.ScopeAdmin, http.StatusNoContent},
+		{http.MethodGet, "/api/v1/transactions/deleted/" + testSender, "", util.ScopeAdmin, http.StatusOK},
+	}
+
+	roles := []struct {
+		name    string
+		scopes  []string
+		granted []string
+	}{
+		{"no scopes", []string{}, nil},
+		{"read", []string{util.ScopeRead}, []string{util.ScopeRead}},
+		{"write", []string{util.ScopeWrite}, []string{util.ScopeWrite}},
+		{"read write", []string{util.ScopeRead, util.ScopeWrite}, []string{util.ScopeRead, util.ScopeWrite}},
+		{"service", []string{util.ScopeService}, []string{util.ScopeRead, util.ScopeWrite}},
+		{"admin", []string{util.ScopeAdmin}, []string{util.ScopeRead, util.ScopeWrite, util.ScopeAdmin}},
+	}
+
+	router := testRouter(t)
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path+" anonymous", func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(route.method, route.path, strings.NewReader(route.body)))
+
+			if recorder.Code != http.StatusUnauthorized {
+				t.Errorf("got status %d, want %d", recorder.Code, http.StatusUnauthorized)
+			}
+		})
+
+		for _, role := range roles {
+			allowed := false
+			for _, scope := range role.granted {
+				allowed = allowed || scope == route.scope
+			}
+
+			t.Run(route.method+" "+route.path+" "+role.name, func(t *testing.T) {
+				req := httptest.NewRequest(route.method, route.path, strings.NewReader(route.body))
+				req.Header.Set("Authorization", testToken(t, role.scopes))
+				req.Header.Set("Content-Type", "application/json")
+
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, req)
+
+				want := http.StatusForbidden
+				if allowed {
+					want = route.status
+				}
+				if recorder.Code != want {
+					t.Errorf("got status %d, want %d: %s", recorder.Code, want, recorder.Body)
+				}
+			})
+		}
+	}
+}
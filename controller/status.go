@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"main/db"
+	"main/model"
+	"main/request"
+	"main/util"
+	"net/http"
+	"time"
+)
+
+//	@description	Move transaction to a new status. Allowed transitions: pending to authorized, failed or cancelled, and authorized to settled, failed or cancelled. Settling captures the held funds for the recipient, failing or cancelling an authorized transaction releases them to the sender. Only the recipient, a service or an admin can settle or cancel a transaction, only a service or an admin can authorize or fail it.
+//	@summary		Change transaction status
+//	@accept			json
+//	@produce		json
+//	@tags			transaction
+//	@param			transactionID	path		string					true	"Transaction ID"
+//	@param			requestBody		body		request.StatusRequest	true	"Status data"
+//	@success		200				{object}	model.Transaction
+//	@failure		400				{object}	response.ErrorResponse
+//	@failure		403				{object}	response.ErrorResponse
+//	@failure		404				{object}	response.ErrorResponse
+//	@failure		409				{object}	response.ErrorResponse
+//	@failure		500				{object}	response.ErrorResponse
+//	@security		JWT
+//	@param			Authorization	header	string	true	"Authorization"
+//	@router			/transaction/{transactionID}/status [PATCH]
+func (receiver TransactionController) UpdateStatus(ctx *gin.Context) {
+	var req request.StatusRequest
+	if !bind(ctx, &req) {
+		return
+	}
+
+	if !req.Status.IsValid() {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid status, supported: 'authorized', 'settled', 'failed', 'cancelled'"))
+		return
+	}
+
+	receiver.transition(req.Status, req.Reason, ctx)
+}
+
+//	@description	Cancel a pending or authorized transaction and release its held funds. Only the recipient, a service or an admin can cancel a transaction.
+//	@summary		Cancel transaction
+//	@accept			json
+//	@produce		json
+//	@tags			transaction
+//	@param			transactionID	path		string	true	"Transaction ID"
+//	@param			reason			query		string	false	"Reason for the cancellation"
+//	@success		200				{object}	model.Transaction
+//	@failure		400				{object}	response.ErrorResponse
+//	@failure		403				{object}	response.ErrorResponse
+//	@failure		404				{object}	response.ErrorResponse
+//	@failure		409				{object}	response.ErrorResponse
+//	@failure		500				{object}	response.ErrorResponse
+//	@security		JWT
+//	@param			Authorization	header	string	true	"Authorization"
+//	@router			/transaction/{transactionID}/cancel [POST]
+func (receiver TransactionController) Cancel(ctx *gin.Context) {
+	receiver.transition(model.Cancelled, ctx.Query("reason"), ctx)
+}
+
+func (receiver TransactionController) transition(status model.Status, reason string, ctx *gin.Context) {
+	transactionID := ctx.Param("transactionID")
+
+	if !util.IsValidUUID(transactionID) {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid transaction id"))
+		return
+	}
+
+	if len(reason) > 255 {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid reason, maximum length is 255"))
+		return
+	}
+
+	tr, err := receiver.DB.Get(transactionID, ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		fail(ctx, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !receiver.authorize(ctx, transitionParties(tr, status)...) {
+		return
+	}
+
+	change := model.StatusChange{
+		Status: status,
+		Date:   time.Now(),
+		By:     util.Subject(ctx),
+		Reason: reason,
+	}
+
+	err = receiver.DB.Transition(transactionID, change, ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		fail(ctx, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, db.ErrIllegalTransition) {
+		fail(ctx, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	tr, err = receiver.DB.Get(transactionID, ctx)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, tr)
+}
+
+// transitionParties returns the accounts whose owners may move the transaction to status, besides
+// admins and services. The payer never can, so it can't take back a payment the merchant relies on.
+func transitionParties(tr model.Transaction, status model.Status) []string {
+	switch status {
+	case model.Settled, model.Cancelled:
+		// Capturing and voiding are up to the merchant.
+		return []string{tr.RecipientID}
+	default:
+		// Authorizations and failures are reported by the payment processor.
+		return nil
+	}
+}
@@ -1,10 +1,13 @@
 package controller
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"main/db"
+	"main/fx"
 	"main/model"
 	"main/request"
 	"main/response"
@@ -13,8 +16,36 @@ import (
 	"time"
 )
 
+// TransactionStore persists transactions, their types and the ledger. It is implemented by db.TransactionDB.
+type TransactionStore interface {
+	Create(transaction model.Transaction, sender model.Account, key *model.IdempotencyKey, ctx *gin.Context) error
+	Get(id string, ctx *gin.Context) (model.Transaction, error)
+	GetAll(id, t string, deleted bool, filter model.TransactionFilter, ctx *gin.Context) ([]model.Transaction,
+		string, error)
+	Reverse(id string, amount model.Amount, payer model.Account, by string, ctx *gin.Context) (model.Transaction,
+		error)
+	Transition(id string, change model.StatusChange, ctx *gin.Context) error
+	Delete(id string, deletion model.Deletion, ctx *gin.Context) error
+	DeleteForAccount(id string, deletion model.Deletion, ctx *gin.Context) error
+	GetBalance(accountID string, ctx *gin.Context) ([]model.Balance, error)
+	GetIdempotencyKey(subject, key string, ctx *gin.Context) (model.IdempotencyKey, bool, error)
+	GetType(id int, ctx *gin.Context) (model.TransactionType, error)
+	GetTypes(ctx *gin.Context) ([]model.TransactionType, error)
+	CreateType(name string, requiresCapture bool, ctx *gin.Context) (model.TransactionType, error)
+	RenameType(id int, name string, ctx *gin.Context) (model.TransactionType, error)
+	DeactivateType(id int, ctx *gin.Context) (model.TransactionType, error)
+}
+
 type TransactionController struct {
-	DB *db.TransactionDB
+	DB TransactionStore
+	// How long Idempotency-Key values are remembered
+	IdempotencyTTL time.Duration
+	// Exchange rates for conversions, nil disables them
+	Rates fx.RateProvider
+	// Account API client
+	Accounts *util.AccountClient
+	// Resolves which accounts the caller owns
+	Ownership *util.Ownership
 }
 
 //	@description	Create new transaction.
@@ -25,77 +56,214 @@ type TransactionController struct {
 //	@param			requestBody	body		request.TransactionRequest	true	"Transaction data"
 //	@success		201			{object}	model.Transaction
 //	@failure		400			{object}	response.ErrorResponse
+//	@failure		403			{object}	response.ErrorResponse
+//	@failure		409			{object}	response.ErrorResponse
+//	@failure		422			{object}	response.ErrorResponse
 //	@failure		500			{object}	response.ErrorResponse
+//	@failure		502			{object}	response.ErrorResponse
+//	@failure		503			{object}	response.ErrorResponse
 //	@security		JWT
 //	@param			Authorization	header	string	true	"Authorization"
+//	@param			Idempotency-Key	header	string	false	"Key used to safely retry the request"
 //	@router			/transaction [POST]
 func (receiver TransactionController) Create(ctx *gin.Context) {
 	var req request.TransactionRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		_ = ctx.Error(err)
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	if !bind(ctx, &req) {
 		return
 	}
 
-	if !util.IsValidUUID(req.SenderAccountID) {
-		err := ctx.Error(errors.New("invalid sender id"))
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
-		return
+	var key *model.IdempotencyKey
+	if value := ctx.GetHeader(IdempotencyKeyHeader); value != "" {
+		if len(value) > 255 {
+			fail(ctx, http.StatusBadRequest, errors.New("invalid idempotency key, maximum length is 255"))
+			return
+		}
+
+		hash, err := requestHash(req)
+		if err != nil {
+			fail(ctx, http.StatusInternalServerError, err)
+			return
+		}
+
+		if receiver.replay(value, hash, ctx) {
+			return
+		}
+		key = &model.IdempotencyKey{Subject: util.Subject(ctx), Key: value, RequestHash: hash}
 	}
 
-	if !util.IsValidUUID(req.RecipientAccountID) {
-		err := ctx.Error(errors.New("invalid recipient id"))
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	transactionType, err := receiver.DB.GetType(req.Type, ctx)
+	if errors.Is(err, db.ErrTypeNotFound) {
+		fail(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
-	if req.Amount < 1 {
-		err := ctx.Error(errors.New("invalid amount, minimum is 1"))
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	if !transactionType.Active {
+		fail(ctx, http.StatusUnprocessableEntity, db.ErrTypeInactive)
 		return
 	}
 
-	acc, err := util.GetAccount(req.SenderAccountID, ctx.MustGet("token").(string),
-		ctx.GetString("Correlation"))
+	acc, err := receiver.Accounts.Get(req.SenderAccountID, ctx)
 	if err != nil {
-		_ = ctx.Error(err)
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		accountFailure(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if !util.IsPrivileged(ctx) && !receiver.Ownership.Remember(req.SenderAccountID, acc.CustomerID, ctx) {
+		forbidden(ctx)
 		return
 	}
 
 	ok, err := util.ValidateAccount(acc)
 	if !ok {
-		_ = ctx.Error(err)
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusBadRequest, err)
+		return
 	}
 
-	if acc.Amount-req.Amount < float64(-1*acc.Limit) {
-		err := ctx.Error(errors.New("insufficient funds"))
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	if !req.Amount.ValidFor(acc.Currency) {
+		invalidAmount(ctx, acc.Currency)
+		return
+	}
+
+	recipient, err := receiver.Accounts.Get(req.RecipientAccountID, ctx)
+	if errors.Is(err, util.ErrAccountNotFound) {
+		fail(ctx, http.StatusUnprocessableEntity, fmt.Errorf("%w: %v", errInvalidRecipient, err))
+		return
+	}
+	if err != nil {
+		accountFailure(ctx, http.StatusUnprocessableEntity, err)
 		return
 	}
 
+	if ok, err := util.ValidateAccount(recipient); !ok {
+		fail(ctx, http.StatusUnprocessableEntity, fmt.Errorf("%w: %v", errInvalidRecipient, err))
+		return
+	}
+
+	if recipient.CustomerID != req.RecipientID {
+		fail(ctx, http.StatusUnprocessableEntity, fmt.Errorf("%w: account doesn't belong to the recipient",
+			errInvalidRecipient))
+		return
+	}
+
+	rate, err := receiver.rate(acc.Currency, recipient.Currency, req.Convert)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	recipientAmount, err := rate.Convert(req.Amount, recipient.Currency)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	date := time.Now()
+	statuses := model.CreatedStatuses(transactionType)
+
 	tr := model.Transaction{
-		ID:          uuid.NewString(),
-		SenderID:    req.SenderAccountID,
-		RecipientID: req.RecipientAccountID,
-		Amount:      req.Amount,
-		Date:        time.Now(),
-		Type: model.TransactionType{
-			ID:   req.Type,
-			Type: "",
-		},
+		ID:                uuid.NewString(),
+		SenderID:          req.SenderAccountID,
+		RecipientID:       req.RecipientAccountID,
+		Amount:            req.Amount,
+		Currency:          acc.Currency,
+		RecipientAmount:   recipientAmount,
+		RecipientCurrency: recipient.Currency,
+		Rate:              rate,
+		Date:              date,
+		Type:              transactionType,
+		Status:            statuses[len(statuses)-1],
+		History:           model.NewHistory(date, util.Subject(ctx), statuses...),
+	}
+
+	if key != nil {
+		key.StatusCode = http.StatusCreated
+		key.ExpiresAt = tr.Date.Add(receiver.IdempotencyTTL)
+		key.Response, err = json.Marshal(tr)
+		if err != nil {
+			fail(ctx, http.StatusInternalServerError, err)
+			return
+		}
 	}
 
-	err = receiver.DB.Create(tr, ctx)
+	err = receiver.DB.Create(tr, acc, key, ctx)
+	if errors.Is(err, db.ErrIdempotencyKeyExists) && receiver.replay(key.Key, key.RequestHash, ctx) {
+		return
+	}
+	if errors.Is(err, db.ErrInsufficientFunds) {
+		fail(ctx, http.StatusBadRequest, err)
+		return
+	}
 	if err != nil {
-		_ = ctx.Error(err)
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusInternalServerError, err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, tr)
 }
 
+// rate returns the rate from the sender to the recipient currency. Different currencies are only
+// accepted when the client explicitly asked for a conversion.
+func (receiver TransactionController) rate(from, to model.Currency, convert bool) (model.Rate, error) {
+	if from == to {
+		return model.RateOne, nil
+	}
+
+	if !convert {
+		return 0, fmt.Errorf("currency mismatch: sender uses %s, recipient uses %s, set convert to apply "+
+			"an exchange rate", from, to)
+	}
+
+	if !to.IsValid() {
+		return 0, errors.New("invalid recipient currency")
+	}
+
+	if receiver.Rates == nil {
+		return 0, errors.New("currency conversion is not available")
+	}
+	return receiver.Rates.Rate(from, to)
+}
+
+//	@description	Get transaction by ID. The caller must own the sender or the recipient account.
+//	@summary		Get transaction by ID
+//	@accept			json
+//	@produce		json
+//	@tags			transaction
+//	@param			transactionID	path		string	true	"Transaction ID"
+//	@success		200				{object}	model.Transaction
+//	@failure		400				{object}	response.ErrorResponse
+//	@failure		403				{object}	response.ErrorResponse
+//	@failure		404				{object}	response.ErrorResponse
+//	@failure		500				{object}	response.ErrorResponse
+//	@security		JWT
+//	@param			Authorization	header	string	true	"Authorization"
+//	@router			/transaction/id/{transactionID} [GET]
+func (receiver TransactionController) GetByID(ctx *gin.Context) {
+	transactionID := ctx.Param("transactionID")
+
+	if !util.IsValidUUID(transactionID) {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid transaction id"))
+		return
+	}
+
+	tr, err := receiver.DB.Get(transactionID, ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		fail(ctx, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !receiver.authorize(ctx, tr.SenderID, tr.RecipientID) {
+		return
+	}
+	ctx.JSON(http.StatusOK, tr)
+}
+
 //	@description	Get all transactions for a specific account, where that account was sender or recipient.
 //	@summary		Get all transactions for a specific account, where that account was sender or recipient
 //	@accept			json
@@ -103,9 +271,11 @@ func (receiver TransactionController) Create(ctx *gin.Context) {
 //	@tags			transaction
 //	@param			accountID	path		string				true	"Account ID"
 //	@param			type		path		string				true	"Specifies type of returned transactions: ingoing, outgoing or both. Supported values: 'sender', 'recipient', 'all'"
-//	@success		200			{object}	[]model.Transaction	"An array of model.Transaction"
+//	@param			filter		query		request.TransactionFilter	false	"Filters and cursor"
+//	@success		200			{object}	response.TransactionPage	"A page of model.Transaction, newest first"
 //	@success		204			"No Content"
 //	@failure		400			{object}	response.ErrorResponse
+//	@failure		403			{object}	response.ErrorResponse
 //	@failure		500			{object}	response.ErrorResponse
 //	@security		JWT
 //	@param			Authorization	header	string	true	"Authorization"
@@ -114,22 +284,71 @@ func (receiver TransactionController) GetAll(ctx *gin.Context) {
 	accountID := ctx.Param("accountID")
 
 	if !util.IsValidUUID(accountID) {
-		err := ctx.Error(errors.New("invalid account id"))
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusBadRequest, errors.New("invalid account id"))
 		return
 	}
 
 	t := ctx.Param("type")
 	if !(t == "sender" || t == "recipient" || t == "all") {
-		err := ctx.Error(errors.New("invalid type, supported: 'sender', 'recipient', 'all'"))
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusBadRequest, errors.New("invalid type, supported: 'sender', 'recipient', 'all'"))
+		return
+	}
+
+	filter, err := transactionFilter(ctx)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if !receiver.authorize(ctx, accountID) {
+		return
+	}
+
+	res, next, err := receiver.DB.GetAll(accountID, t, false, filter, ctx)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(res) == 0 {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+	ctx.JSON(http.StatusOK, response.TransactionPage{Transactions: res, Next: next})
+}
+
+//	@description	Get all soft-deleted transactions for a specific account, where that account was sender or recipient. Requires the transactions:admin scope.
+//	@summary		Get all soft-deleted transactions for a specific account
+//	@accept			json
+//	@produce		json
+//	@tags			transaction
+//	@param			accountID	path		string				true	"Account ID"
+//	@param			filter		query		request.TransactionFilter	false	"Filters and cursor"
+//	@success		200			{object}	response.TransactionPage	"A page of model.Transaction, newest first"
+//	@success		204			"No Content"
+//	@failure		400			{object}	response.ErrorResponse
+//	@failure		403			{object}	response.ErrorResponse
+//	@failure		500			{object}	response.ErrorResponse
+//	@security		JWT
+//	@param			Authorization	header	string	true	"Authorization"
+//	@router			/transactions/deleted/{accountID} [GET]
+func (receiver TransactionController) GetDeleted(ctx *gin.Context) {
+	accountID := ctx.Param("accountID")
+
+	if !util.IsValidUUID(accountID) {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid account id"))
 		return
 	}
 
-	res, err := receiver.DB.GetAll(accountID, t, ctx)
+	filter, err := transactionFilter(ctx)
 	if err != nil {
-		_ = ctx.Error(err)
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	res, next, err := receiver.DB.GetAll(accountID, "all", true, filter, ctx)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -137,17 +356,46 @@ func (receiver TransactionController) GetAll(ctx *gin.Context) {
 		ctx.Status(http.StatusNoContent)
 		return
 	}
-	ctx.JSON(http.StatusOK, res)
+	ctx.JSON(http.StatusOK, response.TransactionPage{Transactions: res, Next: next})
+}
+
+func transactionFilter(ctx *gin.Context) (model.TransactionFilter, error) {
+	var req request.TransactionFilter
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return model.TransactionFilter{}, err
+	}
+
+	if req.Counterparty != "" && !util.IsValidUUID(req.Counterparty) {
+		return model.TransactionFilter{}, errors.New("invalid counterparty id")
+	}
+	return req.Parse()
 }
 
-//	@description	Delete transaction.
+func deletion(ctx *gin.Context) (model.Deletion, error) {
+	reason := ctx.Query("reason")
+	if len(reason) > 255 {
+		return model.Deletion{}, errors.New("invalid reason, maximum length is 255")
+	}
+
+	return model.Deletion{
+		Date:   time.Now(),
+		By:     util.Subject(ctx),
+		Reason: reason,
+	}, nil
+}
+
+//	@description	Soft-delete transaction. Its ledger postings stay in every balance. Pending and authorized transactions have to be settled, failed or cancelled first. Requires the transactions:admin scope.
 //	@summary		Delete transaction
 //	@accept			json
 //	@produce		json
 //	@tags			transaction
 //	@param			transactionID	path	string	true	"Transaction ID"
+//	@param			reason			query	string	false	"Reason for the deletion"
 //	@success		204				"No Content"
 //	@failure		400				{object}	response.ErrorResponse
+//	@failure		403				{object}	response.ErrorResponse
+//	@failure		404				{object}	response.ErrorResponse
+//	@failure		409				{object}	response.ErrorResponse
 //	@failure		500				{object}	response.ErrorResponse
 //	@security		JWT
 //	@param			Authorization	header	string	true	"Authorization"
@@ -156,28 +404,49 @@ func (receiver TransactionController) Delete(ctx *gin.Context) {
 	transactionID := ctx.Param("transactionID")
 
 	if !util.IsValidUUID(transactionID) {
-		err := ctx.Error(errors.New("invalid transaction id"))
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusBadRequest, errors.New("invalid transaction id"))
+		return
+	}
+
+	del, err := deletion(ctx)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	err := receiver.DB.Delete(transactionID, ctx)
+	_, err = receiver.DB.Get(transactionID, ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		fail(ctx, http.StatusNotFound, err)
+		return
+	}
 	if err != nil {
-		_ = ctx.Error(err)
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = receiver.DB.Delete(transactionID, del, ctx)
+	if errors.Is(err, db.ErrNotFinal) {
+		fail(ctx, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, err)
 		return
 	}
 	ctx.Status(http.StatusNoContent)
 }
 
-//	@description	Delete all transactions for the given sender.
+//	@description	Soft-delete all transactions for the given sender. Nothing is deleted while one of them is pending or authorized. Requires the transactions:admin scope.
 //	@summary		Delete all transactions for the given sender
 //	@accept			json
 //	@produce		json
 //	@tags			transaction
 //	@param			accountID	path	string	true	"Account ID"
+//	@param			reason		query	string	false	"Reason for the deletion"
 //	@success		204			"No Content"
 //	@failure		400			{object}	response.ErrorResponse
+//	@failure		403			{object}	response.ErrorResponse
+//	@failure		409			{object}	response.ErrorResponse
 //	@failure		500			{object}	response.ErrorResponse
 //	@security		JWT
 //	@param			Authorization	header	string	true	"Authorization"
@@ -186,15 +455,27 @@ func (receiver TransactionController) DeleteForAccount(ctx *gin.Context) {
 	accountID := ctx.Param("accountID")
 
 	if !util.IsValidUUID(accountID) {
-		err := ctx.Error(errors.New("invalid account id"))
-		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusBadRequest, errors.New("invalid account id"))
 		return
 	}
 
-	err := receiver.DB.DeleteForAccount(accountID, ctx)
+	del, err := deletion(ctx)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	if !receiver.authorize(ctx, accountID) {
+		return
+	}
+
+	err = receiver.DB.DeleteForAccount(accountID, del, ctx)
+	if errors.Is(err, db.ErrNotFinal) {
+		fail(ctx, http.StatusConflict, err)
+		return
+	}
 	if err != nil {
-		_ = ctx.Error(err)
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusInternalServerError, err)
 		return
 	}
 	ctx.Status(http.StatusNoContent)
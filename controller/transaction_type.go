@@ -1,13 +1,16 @@
 package controller
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
-	_ "main/model"
-	"main/response"
+	"main/db"
+	"main/request"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
-//	@description	Get all transaction types.
+//	@description	Get all transaction types, including inactive ones.
 //	@summary		Get all transaction types
 //	@accept			json
 //	@produce		json
@@ -18,9 +21,133 @@ import (
 func (receiver TransactionController) GetTypes(ctx *gin.Context) {
 	types, err := receiver.DB.GetTypes(ctx)
 	if err != nil {
-		_ = ctx.Error(err)
-		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
+		fail(ctx, http.StatusInternalServerError, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, types)
 }
+
+//	@description	Create new transaction type. Transactions of a type requiring a capture, like card payments, are only authorized when created and settled when captured. Requires the admin scope.
+//	@summary		Create new transaction type
+//	@accept			json
+//	@produce		json
+//	@tags			transaction
+//	@param			requestBody	body		request.TransactionTypeRequest	true	"TransactionType data"
+//	@success		201			{object}	model.TransactionType
+//	@failure		400			{object}	response.ErrorResponse
+//	@failure		403			{object}	response.ErrorResponse
+//	@failure		409			{object}	response.ErrorResponse
+//	@failure		500			{object}	response.ErrorResponse
+//	@security		JWT
+//	@param			Authorization	header	string	true	"Authorization"
+//	@router			/types [POST]
+func (receiver TransactionController) CreateType(ctx *gin.Context) {
+	req, ok := typeRequest(ctx)
+	if !ok {
+		return
+	}
+
+	transactionType, err := receiver.DB.CreateType(req.Type, req.RequiresCapture, ctx)
+	if err != nil {
+		typeError(err, ctx)
+		return
+	}
+	ctx.JSON(http.StatusCreated, transactionType)
+}
+
+//	@description	Rename transaction type. Existing transactions keep the type, and whether the type requires a capture doesn't change. Requires the admin scope.
+//	@summary		Rename transaction type
+//	@accept			json
+//	@produce		json
+//	@tags			transaction
+//	@param			typeID		path		int								true	"TransactionType ID"
+//	@param			requestBody	body		request.TransactionTypeRequest	true	"TransactionType data"
+//	@success		200			{object}	model.TransactionType
+//	@failure		400			{object}	response.ErrorResponse
+//	@failure		403			{object}	response.ErrorResponse
+//	@failure		404			{object}	response.ErrorResponse
+//	@failure		409			{object}	response.ErrorResponse
+//	@failure		500			{object}	response.ErrorResponse
+//	@security		JWT
+//	@param			Authorization	header	string	true	"Authorization"
+//	@router			/types/{typeID} [PATCH]
+func (receiver TransactionController) RenameType(ctx *gin.Context) {
+	id, ok := typeID(ctx)
+	if !ok {
+		return
+	}
+
+	req, ok := typeRequest(ctx)
+	if !ok {
+		return
+	}
+
+	transactionType, err := receiver.DB.RenameType(id, req.Type, ctx)
+	if err != nil {
+		typeError(err, ctx)
+		return
+	}
+	ctx.JSON(http.StatusOK, transactionType)
+}
+
+//	@description	Deactivate transaction type, so it can't be used for new transactions. Types are never deleted, since existing transactions reference them. Requires the admin scope.
+//	@summary		Deactivate transaction type
+//	@accept			json
+//	@produce		json
+//	@tags			transaction
+//	@param			typeID	path		int	true	"TransactionType ID"
+//	@success		200		{object}	model.TransactionType
+//	@failure		400		{object}	response.ErrorResponse
+//	@failure		403		{object}	response.ErrorResponse
+//	@failure		404		{object}	response.ErrorResponse
+//	@failure		500		{object}	response.ErrorResponse
+//	@security		JWT
+//	@param			Authorization	header	string	true	"Authorization"
+//	@router			/types/{typeID} [DELETE]
+func (receiver TransactionController) DeactivateType(ctx *gin.Context) {
+	id, ok := typeID(ctx)
+	if !ok {
+		return
+	}
+
+	transactionType, err := receiver.DB.DeactivateType(id, ctx)
+	if err != nil {
+		typeError(err, ctx)
+		return
+	}
+	ctx.JSON(http.StatusOK, transactionType)
+}
+
+func typeID(ctx *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(ctx.Param("typeID"))
+	if err != nil || id < 1 {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid type id"))
+		return 0, false
+	}
+	return id, true
+}
+
+func typeRequest(ctx *gin.Context) (request.TransactionTypeRequest, bool) {
+	var req request.TransactionTypeRequest
+	if !bind(ctx, &req) {
+		return request.TransactionTypeRequest{}, false
+	}
+
+	req.Type = strings.TrimSpace(req.Type)
+	if req.Type == "" || len(req.Type) > 255 {
+		fail(ctx, http.StatusBadRequest, errors.New("invalid type, expected 1 to 255 characters"))
+		return request.TransactionTypeRequest{}, false
+	}
+	return req, true
+}
+
+func typeError(err error, ctx *gin.Context) {
+	switch {
+	case errors.Is(err, db.ErrTypeNotFound):
+		fail(ctx, http.StatusNotFound, err)
+	case errors.Is(err, db.ErrTypeExists):
+		fail(ctx, http.StatusConflict, err)
+	default:
+		fail(ctx, http.StatusInternalServerError, err)
+	}
+}
@@ -1,6 +1,12 @@
 USE transaction_db;
 
 SET FOREIGN_KEY_CHECKS=0;
+DROP TABLE IF EXISTS outbox;
+DROP TABLE IF EXISTS idempotency_key;
+DROP TABLE IF EXISTS account_lock;
+DROP TABLE IF EXISTS ledger_posting;
+DROP TABLE IF EXISTS transaction_status;
+DROP TABLE IF EXISTS journal_entry;
 DROP TABLE IF EXISTS account_transaction;
 DROP TABLE IF EXISTS transaction_type;
 SET FOREIGN_KEY_CHECKS=1;
@@ -11,12 +17,86 @@ CREATE TABLE account_transaction (
     recipient_id VARCHAR(255) NOT NULL,
     amount DECIMAL(10, 2) NOT NULL,
     t_date DATETIME NOT NUll,
-    fk_t_type INT UNSIGNED NOT NULL
+    fk_t_type INT UNSIGNED NOT NULL,
+    currency CHAR(3) NOT NULL,
+    recipient_amount DECIMAL(10, 2) NOT NULL,
+    recipient_currency CHAR(3) NOT NULL,
+    rate DECIMAL(18, 8) NOT NULL DEFAULT 1,
+    fk_reversal_of VARCHAR(255) NULL,
+    deleted_at DATETIME NULL,
+    deleted_by VARCHAR(255) NULL,
+    delete_reason VARCHAR(255) NULL,
+    purged_at DATETIME NULL,
+    status ENUM('pending', 'authorized', 'settled', 'failed', 'cancelled') NOT NULL DEFAULT 'settled',
+    INDEX idx_account_transaction_deleted (deleted_at),
+    INDEX idx_account_transaction_sender (sender_id, t_date, id_transaction),
+    INDEX idx_account_transaction_recipient (recipient_id, t_date, id_transaction)
 );
 
 CREATE TABLE transaction_type (
     id_transaction_type INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    t_type VARCHAR(255) NOT NULL
+    t_type VARCHAR(255) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    requires_capture BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE INDEX uq_transaction_type_t_type (t_type)
+);
+
+CREATE TABLE transaction_status (
+    id_transaction_status INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    fk_transaction VARCHAR(255) NOT NULL,
+    status ENUM('pending', 'authorized', 'settled', 'failed', 'cancelled') NOT NULL,
+    s_date DATETIME NOT NULL,
+    changed_by VARCHAR(255) NULL,
+    reason VARCHAR(255) NULL
+);
+
+CREATE TABLE journal_entry (
+    id_journal_entry VARCHAR(255) NOT NULL PRIMARY KEY,
+    fk_transaction VARCHAR(255) NOT NULL,
+    e_date DATETIME NOT NULL,
+    description VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE ledger_posting (
+    id_ledger_posting INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    fk_journal_entry VARCHAR(255) NOT NULL,
+    account_id VARCHAR(255) NOT NULL,
+    direction ENUM('debit', 'credit') NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    synced_at DATETIME NULL,
+    INDEX idx_ledger_posting_account (account_id, synced_at)
+);
+
+CREATE TABLE account_lock (
+    account_id VARCHAR(255) NOT NULL PRIMARY KEY,
+    version INT UNSIGNED NOT NULL DEFAULT 0
+);
+
+CREATE TABLE idempotency_key (
+    subject VARCHAR(255) NOT NULL,
+    i_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT UNSIGNED NOT NULL,
+    response TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (subject, i_key),
+    INDEX idx_idempotency_key_expires (expires_at)
+);
+
+CREATE TABLE outbox (
+    id_outbox BIGINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    topic VARCHAR(64) NOT NULL,
+    message_key VARCHAR(255) NOT NULL,
+    correlation VARCHAR(128) NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    delivered_at DATETIME NULL,
+    last_error VARCHAR(255) NULL,
+    INDEX idx_outbox_pending (topic, delivered_at, next_attempt_at),
+    INDEX idx_outbox_delivered (delivered_at)
 );
 
 ALTER TABLE account_transaction
@@ -24,6 +104,35 @@ ADD CONSTRAINT fkc_transaction_type_account_transaction
 FOREIGN KEY (fk_t_type)
 REFERENCES transaction_type(id_transaction_type)
 ON UPDATE CASCADE
+ON DELETE RESTRICT;
+
+ALTER TABLE account_transaction
+ADD CONSTRAINT fkc_account_transaction_reversal
+FOREIGN KEY (fk_reversal_of)
+REFERENCES account_transaction(id_transaction)
+ON UPDATE CASCADE
+ON DELETE RESTRICT;
+
+ALTER TABLE transaction_status
+ADD CONSTRAINT fkc_account_transaction_transaction_status
+FOREIGN KEY (fk_transaction)
+REFERENCES account_transaction(id_transaction)
+ON UPDATE CASCADE
 ON DELETE CASCADE;
 
-INSERT INTO transaction_type (t_type) VALUES ("card-payment"), ("loan-payment"), ("transfer");
+ALTER TABLE journal_entry
+ADD CONSTRAINT fkc_account_transaction_journal_entry
+FOREIGN KEY (fk_transaction)
+REFERENCES account_transaction(id_transaction)
+ON UPDATE CASCADE
+ON DELETE RESTRICT;
+
+ALTER TABLE ledger_posting
+ADD CONSTRAINT fkc_journal_entry_ledger_posting
+FOREIGN KEY (fk_journal_entry)
+REFERENCES journal_entry(id_journal_entry)
+ON UPDATE CASCADE
+ON DELETE RESTRICT;
+
+INSERT INTO transaction_type (t_type, requires_capture) VALUES ("card-payment", TRUE), ("loan-payment", FALSE),
+    ("transfer", FALSE);
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"main/model"
+	"main/util"
+	"time"
+)
+
+// enqueueEvent writes a domain event about the transaction to the outbox in tx.
+func (receiver TransactionDB) enqueueEvent(tx *sql.Tx, eventType, transactionID string, date time.Time,
+	transaction *model.Transaction, deletion *model.Deletion, ctx *gin.Context) error {
+	event := model.Event{
+		ID:            uuid.NewString(),
+		Type:          eventType,
+		Date:          date,
+		TransactionID: transactionID,
+		Transaction:   transaction,
+		Deletion:      deletion,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return receiver.enqueue(tx, model.OutboxMessage{Topic: model.TopicEvent, Key: event.ID,
+		Correlation: util.CorrelationID(ctx), Payload: payload, CreatedAt: date})
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"main/model"
+	"time"
+)
+
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+// GetIdempotencyKey returns the key stored by subject, or false if it doesn't exist or has expired.
+func (receiver TransactionDB) GetIdempotencyKey(subject, key string, ctx *gin.Context) (_ model.IdempotencyKey, _ bool,
+	err error) {
+	span := startRequestSpan(ctx, "GetIdempotencyKey")
+	defer endSpan(span, &err)
+
+	stmt, err := receiver.DB.Prepare("SELECT subject, i_key, request_hash, status_code, response, expires_at " +
+		"FROM idempotency_key WHERE subject = ? AND i_key = ? AND expires_at > ?;")
+	if err != nil {
+		return model.IdempotencyKey{}, false, err
+	}
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("stmt.Close() error: %v", err)))
+		}
+	}(stmt)
+
+	var result model.IdempotencyKey
+	var expiresAt string
+
+	err = stmt.QueryRow(subject, key, time.Now().Format("2006-01-02 15:04:05")).Scan(&result.Subject, &result.Key,
+		&result.RequestHash, &result.StatusCode, &result.Response, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.IdempotencyKey{}, false, nil
+	}
+	if err != nil {
+		return model.IdempotencyKey{}, false, err
+	}
+
+	result.ExpiresAt, err = time.Parse("2006-01-02 15:04:05", expiresAt)
+	if err != nil {
+		return model.IdempotencyKey{}, false, err
+	}
+	return result, true, nil
+}
+
+// storeIdempotencyKey replaces an expired key with the same value, so a live key is never overwritten.
+func (receiver TransactionDB) storeIdempotencyKey(tx *sql.Tx, key model.IdempotencyKey) error {
+	_, err := tx.Exec("DELETE FROM idempotency_key WHERE subject = ? AND i_key = ? AND expires_at <= ?;",
+		key.Subject, key.Key, time.Now().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO idempotency_key (subject, i_key, request_hash, status_code, response, "+
+		"expires_at) VALUES (?,?,?,?,?,?);", key.Subject, key.Key, key.RequestHash, key.StatusCode, key.Response,
+		key.GetExpiresAt())
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrIdempotencyKeyExists
+	}
+	return err
+}
+
+// PruneIdempotencyKeys removes keys that expired before the given time.
+func (receiver TransactionDB) PruneIdempotencyKeys(ctx context.Context, before time.Time) (_ int64, err error) {
+	span := startSpan(ctx, "PruneIdempotencyKeys")
+	defer endSpan(span, &err)
+
+	res, err := receiver.DB.ExecContext(ctx, "DELETE FROM idempotency_key WHERE expires_at < ?;",
+		before.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"main/model"
+	"main/util"
+	"time"
+)
+
+var (
+	ErrUnbalancedEntry   = errors.New("unbalanced journal entry")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+// lockAccount takes an exclusive row lock on the account, held until tx commits or rolls back.
+// Concurrent transactions from the same account are serialized here.
+func (receiver TransactionDB) lockAccount(tx *sql.Tx, accountID string) error {
+	_, err := tx.Exec("INSERT INTO account_lock (account_id) VALUES (?) "+
+		"ON DUPLICATE KEY UPDATE version = version + 1;", accountID)
+	return err
+}
+
+// syncMargin is subtracted from the snapshot time before comparing it with synced_at. It covers the
+// time the account service takes to apply a delivered update, clock skew between instances and the
+// whole-second precision of synced_at.
+const syncMargin = time.Minute
+
+// reserve checks, under the account lock, that the sender can cover amount. sender is the account API
+// snapshot, fetched before the SQL transaction began so no HTTP call is made while the lock is held.
+// Debits not synced yet, or synced too late to be sure the snapshot includes them, are subtracted from
+// it. The account API may already have applied some of them, which only makes the check stricter.
+// Credits are left out for the same reason, so a posting is never counted twice in the sender's favour.
+// Postings of soft-deleted transactions count as well, like in GetBalance.
+func (receiver TransactionDB) reserve(tx *sql.Tx, accountID string, sender model.Account, amount model.Amount) error {
+	if err := receiver.lockAccount(tx, accountID); err != nil {
+		return err
+	}
+
+	var pending model.Amount
+	err := tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM ledger_posting WHERE account_id = ? "+
+		"AND direction = 'debit' AND (synced_at IS NULL OR synced_at >= ?);", accountID,
+		sender.FetchedAt.Add(-syncMargin).Format("2006-01-02 15:04:05")).Scan(&pending)
+	if err != nil {
+		return err
+	}
+
+	if sender.Amount-pending-amount < sender.Overdraft() {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+func (receiver TransactionDB) createEntry(tx *sql.Tx, entry model.JournalEntry, ctx *gin.Context) error {
+	if !entry.IsBalanced() {
+		return ErrUnbalancedEntry
+	}
+
+	_, err := tx.Exec("INSERT INTO journal_entry (id_journal_entry, fk_transaction, e_date, description) "+
+		"VALUES (?,?,?,?);", entry.ID, entry.TransactionID, entry.GetDate(), entry.Description)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO ledger_posting (fk_journal_entry, account_id, direction, amount, " +
+		"currency, synced_at) VALUES (?,?,?,?,?,?);")
+	if err != nil {
+		return err
+	}
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("stmt.Close() error: %v", err)))
+		}
+	}(stmt)
+
+	for _, posting := range entry.Postings {
+		// Clearing accounts aren't known to the account service, so there is nothing to push.
+		syncedAt := sql.NullString{String: entry.GetDate(), Valid: model.IsInternalAccount(posting.AccountID)}
+
+		if _, err := stmt.Exec(entry.ID, posting.AccountID, posting.Direction, posting.Amount,
+			posting.Currency, syncedAt); err != nil {
+			return err
+		}
+	}
+	return receiver.enqueueBalanceUpdates(tx, entry, util.CorrelationID(ctx))
+}
+
+// GetBalance returns the balance of the account per currency, since postings in different currencies
+// can't be added up.
+func (receiver TransactionDB) GetBalance(accountID string, ctx *gin.Context) (_ []model.Balance, err error) {
+	span := startRequestSpan(ctx, "GetBalance")
+	defer endSpan(span, &err)
+
+	// Soft-deleting a transaction only hides its row, the money it moved stays in the balance.
+	stmt, err := receiver.DB.Prepare("SELECT currency, COALESCE(SUM(CASE WHEN direction = 'debit' THEN amount END), 0), " +
+		"COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount END), 0) FROM ledger_posting " +
+		"WHERE account_id = ? GROUP BY currency ORDER BY currency;")
+	if err != nil {
+		return nil, err
+	}
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("stmt.Close() error: %v", err)))
+		}
+	}(stmt)
+
+	rows, err := stmt.Query(accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("rows.Close() error: %v", err)))
+		}
+	}(rows)
+
+	balances := []model.Balance{}
+	for rows.Next() {
+		balance := model.Balance{AccountID: accountID}
+		if err := rows.Scan(&balance.Currency, &balance.Debit, &balance.Credit); err != nil {
+			return nil, err
+		}
+		balance.Balance = balance.Credit - balance.Debit
+		balances = append(balances, balance)
+	}
+	return balances, rows.Err()
+}
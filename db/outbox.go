@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"main/model"
+	"time"
+)
+
+// enqueue writes the message to the outbox in tx, so it is delivered only if tx commits.
+func (receiver TransactionDB) enqueue(tx *sql.Tx, message model.OutboxMessage) error {
+	correlation := sql.NullString{String: message.Correlation, Valid: message.Correlation != ""}
+
+	_, err := tx.Exec("INSERT INTO outbox (topic, message_key, correlation, payload, created_at, "+
+		"next_attempt_at) VALUES (?,?,?,?,?,?);", message.Topic, message.Key, correlation, message.Payload,
+		message.GetCreatedAt(), message.GetCreatedAt())
+	return err
+}
+
+// enqueueBalanceUpdates writes a balance update for each account of the entry to the outbox.
+func (receiver TransactionDB) enqueueBalanceUpdates(tx *sql.Tx, entry model.JournalEntry,
+	correlation string) error {
+	for _, update := range entry.BalanceUpdates() {
+		payload, err := json.Marshal(update)
+		if err != nil {
+			return err
+		}
+
+		if err := receiver.enqueue(tx, model.OutboxMessage{Topic: model.TopicBalance, Key: update.ID,
+			Correlation: correlation, Payload: payload, CreatedAt: entry.Date}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimOutbox returns up to limit messages of the topic that are due, and hides them from other
+// relays for lease. A message that isn't marked delivered before the lease ends is claimed again.
+// It isn't traced, since the relays poll it every interval.
+func (receiver TransactionDB) ClaimOutbox(ctx context.Context, topic string, limit int,
+	lease time.Duration) ([]model.OutboxMessage, error) {
+	tx, err := receiver.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func(tx *sql.Tx) {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("tx.Rollback() error: %v", err)
+		}
+	}(tx)
+
+	now := time.Now()
+	rows, err := tx.QueryContext(ctx, "SELECT id_outbox, topic, message_key, correlation, payload, created_at, "+
+		"attempts "+
+		"FROM outbox WHERE topic = ? AND delivered_at IS NULL AND next_attempt_at <= ? ORDER BY id_outbox "+
+		"LIMIT ? FOR UPDATE SKIP LOCKED;", topic, now.Format("2006-01-02 15:04:05"), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []model.OutboxMessage
+	for rows.Next() {
+		var message model.OutboxMessage
+		var createdAt string
+		var correlation sql.NullString
+
+		if err := rows.Scan(&message.ID, &message.Topic, &message.Key, &correlation, &message.Payload,
+			&createdAt, &message.Attempts); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+
+		message.Correlation = correlation.String
+		message.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAt)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, message := range messages {
+		if _, err := tx.ExecContext(ctx, "UPDATE outbox SET next_attempt_at = ? WHERE id_outbox = ?;",
+			now.Add(lease).Format("2006-01-02 15:04:05"), message.ID); err != nil {
+			return nil, err
+		}
+	}
+	return messages, tx.Commit()
+}
+
+// Delivered marks the message as delivered. For a balance update, the postings it covers are marked
+// as synced in the same SQL transaction, so reserve stops adding them to the account API balance.
+func (receiver TransactionDB) Delivered(ctx context.Context, message model.OutboxMessage) (err error) {
+	span := startSpan(ctx, "Delivered")
+	defer endSpan(span, &err)
+
+	tx, err := receiver.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("tx.Rollback() error: %v", err)
+		}
+	}(tx)
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	if _, err := tx.ExecContext(ctx, "UPDATE outbox SET delivered_at = ?, attempts = attempts + 1, "+
+		"last_error = NULL WHERE id_outbox = ?;", now, message.ID); err != nil {
+		return err
+	}
+
+	if message.Topic == model.TopicBalance {
+		var update model.BalanceUpdate
+		if err := json.Unmarshal(message.Payload, &update); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE ledger_posting SET synced_at = ? WHERE fk_journal_entry = ? "+
+			"AND account_id = ?;", now, update.EntryID, update.AccountID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Failed records a failed delivery and schedules the next attempt.
+func (receiver TransactionDB) Failed(ctx context.Context, message model.OutboxMessage, next time.Time,
+	cause error) (err error) {
+	span := startSpan(ctx, "Failed")
+	defer endSpan(span, &err)
+
+	lastError := cause.Error()
+	if len(lastError) > 255 {
+		lastError = lastError[:255]
+	}
+
+	_, err = receiver.DB.ExecContext(ctx, "UPDATE outbox SET attempts = attempts + 1, next_attempt_at = ?, "+
+		"last_error = ? WHERE id_outbox = ?;", next.Format("2006-01-02 15:04:05"), lastError, message.ID)
+	return err
+}
+
+// PruneOutbox removes messages delivered before the given time.
+func (receiver TransactionDB) PruneOutbox(ctx context.Context, before time.Time) (_ int64, err error) {
+	span := startSpan(ctx, "PruneOutbox")
+	defer endSpan(span, &err)
+
+	res, err := receiver.DB.ExecContext(ctx, "DELETE FROM outbox WHERE delivered_at < ?;",
+		before.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"main/model"
+	"time"
+)
+
+var (
+	ErrRefundExceeded     = errors.New("refund exceeds the amount left to refund")
+	ErrReversalOfReversal = errors.New("a reversal can't be reversed")
+	ErrNotSettled         = errors.New("only settled transactions can be reversed")
+)
+
+// Reverse refunds amount of the transaction with a linked compensating transaction. A zero amount
+// refunds everything that is left. The original row stays locked until commit, so concurrent partial
+// refunds can't exceed the original amount together.
+func (receiver TransactionDB) Reverse(id string, amount model.Amount, payer model.Account, by string,
+	ctx *gin.Context) (_ model.Transaction, err error) {
+	span := startRequestSpan(ctx, "Reverse")
+	defer endSpan(span, &err)
+
+	tx, err := receiver.DB.Begin()
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	defer rollback(tx, ctx)
+
+	var locked string
+	err = tx.QueryRow("SELECT id_transaction FROM account_transaction WHERE id_transaction = ? "+
+		"AND deleted_at IS NULL FOR UPDATE;", id).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Transaction{}, ErrNotFound
+	}
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	original, err := scanTransaction(tx.QueryRow(selectTransaction+" WHERE acT.id_transaction = ? AND acT.deleted_at IS NULL;",
+		id))
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	if original.ReversalOf != "" {
+		return model.Transaction{}, ErrReversalOfReversal
+	}
+
+	if original.Status != model.Settled {
+		return model.Transaction{}, ErrNotSettled
+	}
+
+	left := original.Amount - original.Refunded
+	if amount == 0 {
+		amount = left
+	}
+	if amount <= 0 || amount > left {
+		return model.Transaction{}, ErrRefundExceeded
+	}
+
+	reversal, err := original.Reversal(uuid.NewString(), amount, time.Now(), by)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+	if err := receiver.reserve(tx, reversal.SenderID, payer, reversal.Amount); err != nil {
+		return model.Transaction{}, err
+	}
+
+	if err := receiver.insert(tx, reversal, ctx); err != nil {
+		return model.Transaction{}, err
+	}
+
+	if err := receiver.enqueueEvent(tx, model.EventTransactionReversed, id, reversal.Date, &reversal,
+		nil, ctx); err != nil {
+		return model.Transaction{}, err
+	}
+	return reversal, tx.Commit()
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"main/model"
+	"time"
+)
+
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+func (receiver TransactionDB) addStatus(tx *sql.Tx, transactionID string, change model.StatusChange) error {
+	by := sql.NullString{String: change.By, Valid: change.By != ""}
+	reason := sql.NullString{String: change.Reason, Valid: change.Reason != ""}
+
+	_, err := tx.Exec("INSERT INTO transaction_status (fk_transaction, status, s_date, changed_by, reason) "+
+		"VALUES (?,?,?,?,?);", transactionID, change.Status, change.GetDate(), by, reason)
+	return err
+}
+
+func (receiver TransactionDB) getHistory(transactionID string, ctx *gin.Context) ([]model.StatusChange, error) {
+	stmt, err := receiver.DB.Prepare("SELECT status, s_date, changed_by, reason FROM transaction_status " +
+		"WHERE fk_transaction = ? ORDER BY id_transaction_status;")
+	if err != nil {
+		return nil, err
+	}
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("stmt.Close() error: %v", err)))
+		}
+	}(stmt)
+
+	rows, err := stmt.Query(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("rows.Close() error: %v", err)))
+		}
+	}(rows)
+
+	var history []model.StatusChange
+
+	for rows.Next() {
+		var result model.StatusChange
+		var sDate string
+		var by, reason sql.NullString
+
+		if err := rows.Scan(&result.Status, &sDate, &by, &reason); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("rows.Scan() error: %v", err)))
+			continue
+		}
+
+		result.Date, err = time.Parse("2006-01-02 15:04:05", sDate)
+		if err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("time.Parse() error: %v", err)))
+			continue
+		}
+
+		result.By = by.String
+		result.Reason = reason.String
+		history = append(history, result)
+	}
+	if err := rows.Err(); err != nil {
+		_ = ctx.Error(errors.New(fmt.Sprintf("rows.Err() error: %v", err)))
+	}
+	return history, nil
+}
+
+// Transition moves the transaction to change.Status, if the state machine allows it. Capturing an
+// authorized transaction posts the entry moving the held funds to the recipient, failing or
+// cancelling it posts the entry releasing them to the sender.
+func (receiver TransactionDB) Transition(id string, change model.StatusChange, ctx *gin.Context) (err error) {
+	span := startRequestSpan(ctx, "Transition")
+	defer endSpan(span, &err)
+
+	tx, err := receiver.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx, ctx)
+
+	var status model.Status
+	err = tx.QueryRow("SELECT status FROM account_transaction WHERE id_transaction = ? AND deleted_at IS NULL "+
+		"FOR UPDATE;", id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !status.CanTransition(change.Status) {
+		return fmt.Errorf("%w: %s to %s", ErrIllegalTransition, status, change.Status)
+	}
+
+	transaction, err := scanTransaction(tx.QueryRow(selectTransaction+" WHERE acT.id_transaction = ?;", id))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE account_transaction SET status = ? WHERE id_transaction = ?;", change.Status, id)
+	if err != nil {
+		return err
+	}
+
+	if err := receiver.addStatus(tx, id, change); err != nil {
+		return err
+	}
+
+	if entry, ok := transaction.TransitionEntry(uuid.NewString(), change.Status, change.Date); ok {
+		if err := receiver.createEntry(tx, entry, ctx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
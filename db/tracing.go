@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"main/tracing"
+)
+
+var tracer = otel.Tracer("main/db")
+
+// startSpan starts a span covering the queries of a TransactionDB operation.
+func startSpan(ctx context.Context, operation string) trace.Span {
+	_, span := tracer.Start(ctx, "TransactionDB."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemMySQL, attribute.String("db.operation", operation)))
+	return span
+}
+
+// startRequestSpan starts the span as a child of the request's span.
+func startRequestSpan(ctx *gin.Context, operation string) trace.Span {
+	return startSpan(ctx.Request.Context(), operation)
+}
+
+// endSpan records the error returned by the operation, if any, and ends the span.
+func endSpan(span trace.Span, err *error) {
+	tracing.End(span, *err)
+}
@@ -1,10 +1,13 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"log"
 	"main/model"
 	"time"
 )
@@ -13,37 +16,229 @@ type TransactionDB struct {
 	DB *sql.DB
 }
 
-func (receiver TransactionDB) Create(transaction model.Transaction, ctx *gin.Context) error {
-	stmt, err := receiver.DB.Prepare("INSERT INTO account_transaction (id_transaction, sender_id, recipient_id, " +
-		"amount, t_date, fk_t_type) VALUES (?,?,?,?,?,?);")
+var (
+	ErrNotFound = errors.New("transaction not found")
+	ErrNotFinal = errors.New("only transactions in a final status can be deleted")
+)
+
+const selectTransaction = "SELECT acT.id_transaction, acT.sender_id, acT.recipient_id, acT.amount, acT.t_date, " +
+	"acT.currency, acT.recipient_amount, acT.recipient_currency, acT.rate, acT.fk_reversal_of, " +
+	"(SELECT COALESCE(SUM(r.recipient_amount), 0) FROM account_transaction AS r " +
+	"WHERE r.fk_reversal_of = acT.id_transaction), (SELECT COALESCE(SUM(r.amount), 0) " +
+	"FROM account_transaction AS r WHERE r.fk_reversal_of = acT.id_transaction), acT.deleted_at, " +
+	"acT.deleted_by, acT.delete_reason, acT.purged_at, acT.status, tt.id_transaction_type, tt.t_type, " +
+	"tt.active, tt.requires_capture " +
+	"FROM account_transaction AS acT JOIN transaction_type AS tt ON acT.fk_t_type = tt.id_transaction_type"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTransaction(row scanner) (model.Transaction, error) {
+	var result model.Transaction
+	var tDate string
+	var reversalOf, deletedAt, deletedBy, deleteReason, purgedAt sql.NullString
+
+	err := row.Scan(&result.ID, &result.SenderID, &result.RecipientID, &result.Amount, &tDate, &result.Currency,
+		&result.RecipientAmount, &result.RecipientCurrency, &result.Rate, &reversalOf, &result.Refunded,
+		&result.PaidBack, &deletedAt, &deletedBy, &deleteReason, &purgedAt, &result.Status, &result.Type.ID,
+		&result.Type.Type, &result.Type.Active, &result.Type.RequiresCapture)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	result.Date, err = time.Parse("2006-01-02 15:04:05", tDate)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	if deletedAt.Valid {
+		date, err := time.Parse("2006-01-02 15:04:05", deletedAt.String)
+		if err != nil {
+			return model.Transaction{}, err
+		}
+		result.DeletedAt = &date
+	}
+
+	if purgedAt.Valid {
+		date, err := time.Parse("2006-01-02 15:04:05", purgedAt.String)
+		if err != nil {
+			return model.Transaction{}, err
+		}
+		result.PurgedAt = &date
+	}
+
+	result.ReversalOf = reversalOf.String
+	result.DeletedBy = deletedBy.String
+	result.DeleteReason = deleteReason.String
+	return result, nil
+}
+
+func (receiver TransactionDB) insert(tx *sql.Tx, transaction model.Transaction, ctx *gin.Context) error {
+	reversalOf := sql.NullString{String: transaction.ReversalOf, Valid: transaction.ReversalOf != ""}
+
+	_, err := tx.Exec("INSERT INTO account_transaction (id_transaction, sender_id, recipient_id, amount, t_date, "+
+		"fk_t_type, currency, recipient_amount, recipient_currency, rate, fk_reversal_of, status) "+
+		"VALUES (?,?,?,?,?,?,?,?,?,?,?,?);", transaction.ID, transaction.SenderID, transaction.RecipientID,
+		transaction.Amount, transaction.GetDate(), transaction.Type.ID, transaction.Currency,
+		transaction.RecipientAmount, transaction.RecipientCurrency, transaction.Rate, reversalOf, transaction.Status)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range transaction.History {
+		if err := receiver.addStatus(tx, transaction.ID, change); err != nil {
+			return err
+		}
+	}
+
+	description := "transfer"
+	if transaction.ReversalOf != "" {
+		description = "reversal"
+	} else if transaction.Status == model.Authorized {
+		description = "hold"
+	}
+	return receiver.createEntry(tx, transaction.CreatedEntry(uuid.NewString(), description), ctx)
+}
+
+func rollback(tx *sql.Tx, ctx *gin.Context) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		_ = ctx.Error(errors.New(fmt.Sprintf("tx.Rollback() error: %v", err)))
+	}
+}
+
+// Create inserts the transaction together with its balanced journal entry in a single SQL transaction.
+// The funds check runs under a row lock on the sender, so concurrent transactions can't overdraw it.
+// If key is set, it is stored in the same SQL transaction, and so is the TransactionCreated event.
+func (receiver TransactionDB) Create(transaction model.Transaction, sender model.Account, key *model.IdempotencyKey,
+	ctx *gin.Context) (err error) {
+	span := startRequestSpan(ctx, "Create")
+	defer endSpan(span, &err)
+
+	tx, err := receiver.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer rollback(tx, ctx)
+
+	if key != nil {
+		if err := receiver.storeIdempotencyKey(tx, *key); err != nil {
+			return err
+		}
+	}
+
+	if err := receiver.reserve(tx, transaction.SenderID, sender, transaction.Amount); err != nil {
+		return err
+	}
+
+	if err := receiver.insert(tx, transaction, ctx); err != nil {
+		return err
+	}
+
+	if err := receiver.enqueueEvent(tx, model.EventTransactionCreated, transaction.ID, transaction.Date,
+		&transaction, nil, ctx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (receiver TransactionDB) Get(id string, ctx *gin.Context) (_ model.Transaction, err error) {
+	span := startRequestSpan(ctx, "Get")
+	defer endSpan(span, &err)
+
+	stmt, err := receiver.DB.Prepare(selectTransaction + " WHERE acT.id_transaction = ? AND acT.deleted_at IS NULL;")
+	if err != nil {
+		return model.Transaction{}, err
+	}
 	defer func(stmt *sql.Stmt) {
 		if err := stmt.Close(); err != nil {
 			_ = ctx.Error(errors.New(fmt.Sprintf("stmt.Close() error: %v", err)))
 		}
 	}(stmt)
 
-	_, err = stmt.Exec(transaction.ID, transaction.SenderID, transaction.RecipientID, transaction.Amount,
-		transaction.GetDate(), transaction.Type.ID)
-	return err
+	result, err := scanTransaction(stmt.QueryRow(id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Transaction{}, ErrNotFound
+	}
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	result.History, err = receiver.getHistory(id, ctx)
+	return result, err
 }
 
-func (receiver TransactionDB) GetAll(id, t string, ctx *gin.Context) ([]model.Transaction, error) {
-	query := "SELECT acT.*, tt.* FROM account_transaction AS acT JOIN transaction_type AS tt ON acT.fk_t_type = tt.id_transaction_type"
+// GetAll returns a page of the account's transactions matching the filter, newest first, and the
+// cursor of the next page. Soft-deleted transactions are returned only, and exclusively, when deleted
+// is true.
+func (receiver TransactionDB) GetAll(id, t string, deleted bool, filter model.TransactionFilter,
+	ctx *gin.Context) (_ []model.Transaction, _ string, err error) {
+	span := startRequestSpan(ctx, "GetAll")
+	defer endSpan(span, &err)
+
+	query := selectTransaction
+	var args []any
+
+	if deleted {
+		query += " WHERE acT.deleted_at IS NOT NULL"
+	} else {
+		query += " WHERE acT.deleted_at IS NULL"
+	}
 
 	if t == "sender" {
-		query += " WHERE sender_id = ?;"
+		query += " AND acT.sender_id = ?"
+		args = append(args, id)
+		if filter.Counterparty != "" {
+			query += " AND acT.recipient_id = ?"
+			args = append(args, filter.Counterparty)
+		}
 	} else if t == "recipient" {
-		query += " WHERE recipient_id = ?;"
+		query += " AND acT.recipient_id = ?"
+		args = append(args, id)
+		if filter.Counterparty != "" {
+			query += " AND acT.sender_id = ?"
+			args = append(args, filter.Counterparty)
+		}
+	} else if filter.Counterparty != "" {
+		query += " AND ((acT.sender_id = ? AND acT.recipient_id = ?) OR (acT.recipient_id = ? AND acT.sender_id = ?))"
+		args = append(args, id, filter.Counterparty, id, filter.Counterparty)
 	} else {
-		query += " WHERE sender_id = ? OR recipient_id = ?;"
+		query += " AND (acT.sender_id = ? OR acT.recipient_id = ?)"
+		args = append(args, id, id)
 	}
 
+	if !filter.From.IsZero() {
+		query += " AND acT.t_date >= ?"
+		args = append(args, filter.From.Local().Format("2006-01-02 15:04:05"))
+	}
+	if !filter.To.IsZero() {
+		query += " AND acT.t_date < ?"
+		args = append(args, filter.To.Local().Format("2006-01-02 15:04:05"))
+	}
+	if filter.MinAmount != nil {
+		query += " AND acT.amount >= ?"
+		args = append(args, *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query += " AND acT.amount <= ?"
+		args = append(args, *filter.MaxAmount)
+	}
+	if filter.Type != 0 {
+		query += " AND acT.fk_t_type = ?"
+		args = append(args, filter.Type)
+	}
+	if filter.After != nil {
+		query += " AND (acT.t_date < ? OR (acT.t_date = ? AND acT.id_transaction < ?))"
+		args = append(args, filter.After.GetDate(), filter.After.GetDate(), filter.After.ID)
+	}
+
+	// One extra row tells whether there is a next page.
+	query += " ORDER BY acT.t_date DESC, acT.id_transaction DESC LIMIT ?;"
+	args = append(args, filter.Limit+1)
+
 	stmt, err := receiver.DB.Prepare(query)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer func(stmt *sql.Stmt) {
 		if err := stmt.Close(); err != nil {
@@ -51,15 +246,9 @@ func (receiver TransactionDB) GetAll(id, t string, ctx *gin.Context) ([]model.Tr
 		}
 	}(stmt)
 
-	var rows *sql.Rows
-	if t == "all" {
-		rows, err = stmt.Query(id, id)
-	} else {
-		rows, err = stmt.Query(id)
-	}
-
+	rows, err := stmt.Query(args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer func(rows *sql.Rows) {
 		if err := rows.Close(); err != nil {
@@ -70,51 +259,133 @@ func (receiver TransactionDB) GetAll(id, t string, ctx *gin.Context) ([]model.Tr
 	var types []model.Transaction
 
 	for rows.Next() {
-		var result model.Transaction
-		var tDate string
-		var tt model.TransactionType
-
-		if err := rows.Scan(&result.ID, &result.SenderID, &result.RecipientID, &result.Amount, &tDate,
-			&tt.ID, &tt.ID, &tt.Type); err != nil {
-			_ = ctx.Error(errors.New(fmt.Sprintf("rows.Scan() error: %v", err)))
-			continue
-		}
-
-		result.Date, err = time.Parse("2006-01-02 15:04:05", tDate)
+		result, err := scanTransaction(rows)
 		if err != nil {
-			_ = ctx.Error(errors.New(fmt.Sprintf("time.Parse() error: %v", err)))
+			_ = ctx.Error(errors.New(fmt.Sprintf("rows.Scan() error: %v", err)))
 			continue
 		}
 
-		result.Type = tt
-
 		types = append(types, result)
 	}
 	if err := rows.Err(); err != nil {
 		_ = ctx.Error(errors.New(fmt.Sprintf("rows.Err() error: %v", err)))
 	}
-	return types, nil
+
+	var next string
+	if len(types) > filter.Limit {
+		types = types[:filter.Limit]
+		next = model.NewCursor(types[len(types)-1]).Encode()
+	}
+	return types, next, nil
 }
 
-func (receiver TransactionDB) delete(id, query string, ctx *gin.Context) error {
-	stmt, err := receiver.DB.Prepare(query)
+// delete soft-deletes the live transactions matching condition, or returns ErrNotFinal if one of them can
+// still change status. It writes a TransactionDeleted event for each of them in the same SQL transaction.
+func (receiver TransactionDB) delete(condition string, deletion model.Deletion, id string, ctx *gin.Context) error {
+	tx, err := receiver.DB.Begin()
 	if err != nil {
 		return err
 	}
-	defer func(stmt *sql.Stmt) {
-		if err := stmt.Close(); err != nil {
-			_ = ctx.Error(errors.New(fmt.Sprintf("stmt.Close() error: %v", err)))
+	defer rollback(tx, ctx)
+
+	rows, err := tx.Query("SELECT id_transaction, status FROM account_transaction WHERE "+condition+
+		" AND deleted_at IS NULL FOR UPDATE;", id)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var transactionID string
+		var status model.Status
+		if err := rows.Scan(&transactionID, &status); err != nil {
+			_ = rows.Close()
+			return err
 		}
-	}(stmt)
+		if !status.IsFinal() {
+			_ = rows.Close()
+			return ErrNotFinal
+		}
+		ids = append(ids, transactionID)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
 
-	_, err = stmt.Exec(id)
-	return err
+	_, err = tx.Exec("UPDATE account_transaction SET deleted_at = ?, deleted_by = ?, delete_reason = ? WHERE "+
+		condition+" AND deleted_at IS NULL;", deletion.GetDate(), deletion.By, deletion.Reason, id)
+	if err != nil {
+		return err
+	}
+
+	for _, transactionID := range ids {
+		if err := receiver.enqueueEvent(tx, model.EventTransactionDeleted, transactionID, deletion.Date, nil,
+			&deletion, ctx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func (receiver TransactionDB) Delete(id string, ctx *gin.Context) error {
-	return receiver.delete(id, "DELETE FROM account_transaction WHERE id_transaction = ?;", ctx)
+// Delete soft-deletes the transaction. The retention job purges its details after the legal hold period.
+// Transactions still holding funds have to be settled, failed or cancelled first, otherwise the hold
+// could never be captured or released.
+func (receiver TransactionDB) Delete(id string, deletion model.Deletion, ctx *gin.Context) (err error) {
+	span := startRequestSpan(ctx, "Delete")
+	defer endSpan(span, &err)
+
+	return receiver.delete("id_transaction = ?", deletion, id, ctx)
 }
 
-func (receiver TransactionDB) DeleteForAccount(id string, ctx *gin.Context) error {
-	return receiver.delete(id, "DELETE FROM account_transaction WHERE sender_id = ?;", ctx)
+// DeleteForAccount soft-deletes all transactions sent from the account. Nothing is deleted if any of them
+// isn't in a final status yet.
+func (receiver TransactionDB) DeleteForAccount(id string, deletion model.Deletion, ctx *gin.Context) (err error) {
+	span := startRequestSpan(ctx, "DeleteForAccount")
+	defer endSpan(span, &err)
+
+	return receiver.delete("sender_id = ?", deletion, id, ctx)
+}
+
+// Purge removes the status history and deletion details of transactions soft-deleted before the given
+// time. The row itself is kept, together with its journal entries, since the ledger is the audit trail
+// of every balance.
+func (receiver TransactionDB) Purge(ctx context.Context, before time.Time) (_ int64, err error) {
+	span := startSpan(ctx, "Purge")
+	defer endSpan(span, &err)
+
+	tx, err := receiver.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func(tx *sql.Tx) {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Printf("tx.Rollback() error: %v", err)
+		}
+	}(tx)
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	res, err := tx.ExecContext(ctx, "UPDATE account_transaction SET deleted_by = NULL, delete_reason = NULL, "+
+		"purged_at = ? WHERE deleted_at < ? AND purged_at IS NULL;", now, before.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE ts FROM transaction_status AS ts JOIN account_transaction AS acT "+
+		"ON ts.fk_transaction = acT.id_transaction WHERE acT.purged_at IS NOT NULL;")
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return purged, tx.Commit()
 }
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"io"
+	"main/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLedger is an in-memory stand-in for the tables reserve and createEntry touch. account_lock rows
+// are mutexes held until commit or rollback, and ledger_posting rows only become visible on commit, like
+// under InnoDB. Every other statement succeeds without doing anything.
+type fakeLedger struct {
+	mu     sync.Mutex
+	locks  map[string]*sync.Mutex
+	debits map[string]model.Amount
+}
+
+func (receiver *fakeLedger) lock(accountID string) *sync.Mutex {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	lock, ok := receiver.locks[accountID]
+	if !ok {
+		lock = &sync.Mutex{}
+		receiver.locks[accountID] = lock
+	}
+	return lock
+}
+
+func (receiver *fakeLedger) debit(accountID string) model.Amount {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return receiver.debits[accountID]
+}
+
+func (receiver *fakeLedger) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{ledger: receiver}, nil
+}
+
+func (receiver *fakeLedger) Driver() driver.Driver {
+	return receiver
+}
+
+func (receiver *fakeLedger) Open(string) (driver.Conn, error) {
+	return receiver.Connect(context.Background())
+}
+
+type fakePosting struct {
+	accountID string
+	amount    model.Amount
+}
+
+type fakeConn struct {
+	ledger   *fakeLedger
+	locked   map[string]*sync.Mutex
+	postings []fakePosting
+}
+
+func (receiver *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{conn: receiver, query: query}, nil
+}
+
+func (receiver *fakeConn) Close() error {
+	return nil
+}
+
+func (receiver *fakeConn) Begin() (driver.Tx, error) {
+	receiver.locked = map[string]*sync.Mutex{}
+	return receiver, nil
+}
+
+func (receiver *fakeConn) Commit() error {
+	receiver.ledger.mu.Lock()
+	for _, posting := range receiver.postings {
+		receiver.ledger.debits[posting.accountID] += posting.amount
+	}
+	receiver.ledger.mu.Unlock()
+	return receiver.Rollback()
+}
+
+func (receiver *fakeConn) Rollback() error {
+	for _, lock := range receiver.locked {
+		lock.Unlock()
+	}
+	receiver.locked = nil
+	receiver.postings = nil
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (receiver fakeStmt) Close() error {
+	return nil
+}
+
+func (receiver fakeStmt) NumInput() int {
+	return -1
+}
+
+func (receiver fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(receiver.query, "INSERT INTO account_lock"):
+		accountID := args[0].(string)
+		if _, ok := receiver.conn.locked[accountID]; !ok {
+			lock := receiver.conn.ledger.lock(accountID)
+			lock.Lock()
+			receiver.conn.locked[accountID] = lock
+		}
+	case strings.HasPrefix(receiver.query, "INSERT INTO ledger_posting"):
+		if args[2] == string(model.Debit) {
+			var amount model.Amount
+			if err := amount.Scan(args[3]); err != nil {
+				return nil, err
+			}
+			receiver.conn.postings = append(receiver.conn.postings, fakePosting{args[1].(string), amount})
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (receiver fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(receiver.query, "SELECT COALESCE(SUM(amount), 0) FROM ledger_posting") {
+		return &fakeRows{}, nil
+	}
+
+	accountID := args[0].(string)
+	if _, ok := receiver.conn.locked[accountID]; !ok {
+		return nil, errors.New("debits of " + accountID + " summed without holding its lock")
+	}
+	return &fakeRows{values: []driver.Value{receiver.conn.ledger.debit(accountID).String()}}, nil
+}
+
+type fakeRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (receiver *fakeRows) Columns() []string {
+	return make([]string, len(receiver.values))
+}
+
+func (receiver *fakeRows) Close() error {
+	return nil
+}
+
+func (receiver *fakeRows) Next(dest []driver.Value) error {
+	if receiver.done || receiver.values == nil {
+		return io.EOF
+	}
+	receiver.done = true
+	copy(dest, receiver.values)
+	return nil
+}
+
+func testDB(t *testing.T) (TransactionDB, *fakeLedger) {
+	t.Helper()
+
+	ledger := &fakeLedger{locks: map[string]*sync.Mutex{}, debits: map[string]model.Amount{}}
+	conn := sql.OpenDB(ledger)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return TransactionDB{DB: conn}, ledger
+}
+
+func testContext() *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/transaction", nil)
+	return ctx
+}
+
+func TestCreateConcurrentNoOverdraft(t *testing.T) {
+	transactionDB, ledger := testDB(t)
+
+	const (
+		workers = 20
+		limit   = 50
+	)
+	balance := model.AmountFromUnits(100)
+	amount := model.AmountFromUnits(10)
+
+	senderID := uuid.NewString()
+	recipientID := uuid.NewString()
+
+	// Nothing is delivered to the account API during the test, so the snapshot never changes and only
+	// the unsynced postings stand between the sender and an overdraft.
+	sender := model.Account{PK: senderID, Amount: balance, Currency: "EUR", Limit: limit, FetchedAt: time.Now()}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			date := time.Now()
+			tr := model.Transaction{
+				ID:                uuid.NewString(),
+				SenderID:          senderID,
+				RecipientID:       recipientID,
+				Amount:            amount,
+				Currency:          "EUR",
+				RecipientAmount:   amount,
+				RecipientCurrency: "EUR",
+				Rate:              model.RateOne,
+				Date:              date,
+				Type:              model.TransactionType{ID: 3},
+				Status:            model.Settled,
+				History:           model.NewHistory(date, "test", model.Pending, model.Authorized, model.Settled),
+			}
+			errs <- transactionDB.Create(tr, sender, nil, testContext())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, ErrInsufficientFunds):
+		default:
+			t.Errorf("Create() error: %v", err)
+		}
+	}
+
+	// 100 plus an overdraft of 50 covers exactly 15 transfers of 10.
+	if want := 15; created != want {
+		t.Errorf("created %d transactions, want %d", created, want)
+	}
+	if final := balance - ledger.debit(senderID); final < sender.Overdraft() {
+		t.Errorf("balance %v is past the overdraft limit %d", final, limit)
+	}
+}
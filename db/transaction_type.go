@@ -5,11 +5,45 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
 	"main/model"
 )
 
-func (receiver TransactionDB) GetTypes(ctx *gin.Context) ([]model.TransactionType, error) {
-	stmt, err := receiver.DB.Prepare("SELECT * FROM transaction_type;")
+var (
+	ErrTypeNotFound = errors.New("transaction type not found")
+	ErrTypeExists   = errors.New("transaction type already exists")
+	ErrTypeInactive = errors.New("transaction type is inactive")
+)
+
+func (receiver TransactionDB) GetType(id int, ctx *gin.Context) (_ model.TransactionType, err error) {
+	span := startRequestSpan(ctx, "GetType")
+	defer endSpan(span, &err)
+
+	stmt, err := receiver.DB.Prepare("SELECT id_transaction_type, t_type, active, requires_capture FROM transaction_type " +
+		"WHERE id_transaction_type = ?;")
+	if err != nil {
+		return model.TransactionType{}, err
+	}
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("stmt.Close() error: %v", err)))
+		}
+	}(stmt)
+
+	var result model.TransactionType
+	err = stmt.QueryRow(id).Scan(&result.ID, &result.Type, &result.Active, &result.RequiresCapture)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.TransactionType{}, ErrTypeNotFound
+	}
+	return result, err
+}
+
+func (receiver TransactionDB) GetTypes(ctx *gin.Context) (_ []model.TransactionType, err error) {
+	span := startRequestSpan(ctx, "GetTypes")
+	defer endSpan(span, &err)
+
+	stmt, err := receiver.DB.Prepare("SELECT id_transaction_type, t_type, active, requires_capture " +
+		"FROM transaction_type;")
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +67,7 @@ func (receiver TransactionDB) GetTypes(ctx *gin.Context) ([]model.TransactionTyp
 
 	for rows.Next() {
 		var result model.TransactionType
-		if err := rows.Scan(&result.ID, &result.Type); err != nil {
+		if err := rows.Scan(&result.ID, &result.Type, &result.Active, &result.RequiresCapture); err != nil {
 			_ = ctx.Error(errors.New(fmt.Sprintf("rows.Scan() error: %v", err)))
 			continue
 		}
@@ -45,3 +79,77 @@ func (receiver TransactionDB) GetTypes(ctx *gin.Context) ([]model.TransactionTyp
 
 	return types, nil
 }
+
+func typeError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrTypeExists
+	}
+	return err
+}
+
+// CreateType adds a new active transaction type. Whether it requires a capture can't be changed later,
+// since it decides how existing transactions of the type are settled.
+func (receiver TransactionDB) CreateType(name string, requiresCapture bool, ctx *gin.Context) (
+	_ model.TransactionType, err error) {
+	span := startRequestSpan(ctx, "CreateType")
+	defer endSpan(span, &err)
+
+	stmt, err := receiver.DB.Prepare("INSERT INTO transaction_type (t_type, requires_capture) VALUES (?,?);")
+	if err != nil {
+		return model.TransactionType{}, err
+	}
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("stmt.Close() error: %v", err)))
+		}
+	}(stmt)
+
+	res, err := stmt.Exec(name, requiresCapture)
+	if err != nil {
+		return model.TransactionType{}, typeError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return model.TransactionType{}, err
+	}
+	return model.TransactionType{ID: int(id), Type: name, Active: true, RequiresCapture: requiresCapture}, nil
+}
+
+func (receiver TransactionDB) updateType(query string, arg any, id int, ctx *gin.Context) (model.TransactionType,
+	error) {
+	stmt, err := receiver.DB.Prepare(query)
+	if err != nil {
+		return model.TransactionType{}, err
+	}
+	defer func(stmt *sql.Stmt) {
+		if err := stmt.Close(); err != nil {
+			_ = ctx.Error(errors.New(fmt.Sprintf("stmt.Close() error: %v", err)))
+		}
+	}(stmt)
+
+	if _, err := stmt.Exec(arg, id); err != nil {
+		return model.TransactionType{}, typeError(err)
+	}
+	return receiver.GetType(id, ctx)
+}
+
+// RenameType changes the name of the type. Existing transactions keep referencing it by ID.
+func (receiver TransactionDB) RenameType(id int, name string, ctx *gin.Context) (_ model.TransactionType, err error) {
+	span := startRequestSpan(ctx, "RenameType")
+	defer endSpan(span, &err)
+
+	return receiver.updateType("UPDATE transaction_type SET t_type = ? WHERE id_transaction_type = ?;", name, id,
+		ctx)
+}
+
+// DeactivateType stops the type from being used for new transactions. Types are never deleted, since
+// existing transactions reference them.
+func (receiver TransactionDB) DeactivateType(id int, ctx *gin.Context) (_ model.TransactionType, err error) {
+	span := startRequestSpan(ctx, "DeactivateType")
+	defer endSpan(span, &err)
+
+	return receiver.updateType("UPDATE transaction_type SET active = ? WHERE id_transaction_type = ?;", false, id,
+		ctx)
+}
@@ -0,0 +1,57 @@
+package fx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/model"
+	"os"
+)
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// RateProvider returns the rate used to convert an amount in from into to.
+type RateProvider interface {
+	Rate(from, to model.Currency) (model.Rate, error)
+}
+
+// FileProvider serves rates from a JSON file of the form {"EUR": {"USD": "1.0712"}}.
+type FileProvider struct {
+	rates map[model.Currency]map[model.Currency]model.Rate
+}
+
+func NewFileProvider(fileName string) (*FileProvider, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates map[model.Currency]map[model.Currency]model.Rate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, err
+	}
+
+	for from, to := range rates {
+		if !from.IsValid() {
+			return nil, fmt.Errorf("%w: %q", model.ErrInvalidCurrency, from)
+		}
+		for currency := range to {
+			if !currency.IsValid() {
+				return nil, fmt.Errorf("%w: %q", model.ErrInvalidCurrency, currency)
+			}
+		}
+	}
+	return &FileProvider{rates: rates}, nil
+}
+
+func (receiver *FileProvider) Rate(from, to model.Currency) (model.Rate, error) {
+	if from == to {
+		return model.RateOne, nil
+	}
+
+	rate, ok := receiver.rates[from][to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+	}
+	return rate, nil
+}
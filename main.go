@@ -8,20 +8,29 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	swaggerFiles "github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"log"
 	"main/controller"
 	"main/db"
 	_ "main/docs"
 	"main/env"
+	"main/fx"
 	"main/messaging"
+	"main/model"
+	"main/outbox"
+	"main/retention"
+	"main/tracing"
 	"main/util"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
 
+const serviceName = "transaction-api"
+
 //	@title			cr24 Transaction API
 //	@version		1.0
 //	@description	API for transaction management for cr24 project
@@ -49,6 +58,11 @@ func main() {
 		log.Fatalf("failed to load env variables: %v", err)
 	}
 
+	shutdownTracing, err := tracing.Init(context.Background(), os.Getenv("TRACE_EXPORTER"), serviceName)
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+
 	uri := os.Getenv("MYSQL_URL")
 	if uri == "" {
 		log.Fatal("MYSQL_URL is not set")
@@ -77,36 +91,106 @@ func main() {
 		}
 	}(mysqlDB)
 
+	var rates fx.RateProvider
+	if fileName := os.Getenv("FX_RATES_FILE"); fileName != "" {
+		rates, err = fx.NewFileProvider(fileName)
+		if err != nil {
+			log.Fatalf("failed to load exchange rates: %v", err)
+		}
+	}
+
+	accountURL := os.Getenv("ACCOUNT_API_URL")
+	if accountURL == "" {
+		accountURL = "http://account-api:8080"
+	}
+	accounts := util.NewAccountClient(accountURL, os.Getenv("ACCOUNT_API_TOKEN"),
+		duration("ACCOUNT_API_TIMEOUT", 5*time.Second))
+
+	transactionDB := &db.TransactionDB{
+		DB: mysqlDB,
+	}
+
 	transactionController := controller.TransactionController{
-		DB: &db.TransactionDB{
-			DB: mysqlDB,
-		},
+		DB:             transactionDB,
+		IdempotencyTTL: duration("IDEMPOTENCY_TTL", 24*time.Hour),
+		Rates:          rates,
+		Accounts:       accounts,
+		Ownership:      util.NewOwnership(accounts, duration("OWNERSHIP_CACHE_TTL", 5*time.Minute)),
+	}
+
+	retentionJob := retention.Job{
+		DB:         transactionDB,
+		Hold:       duration("RETENTION_HOLD", 10*365*24*time.Hour),
+		OutboxHold: duration("OUTBOX_RETENTION", 7*24*time.Hour),
+		Interval:   duration("RETENTION_INTERVAL", 24*time.Hour),
+	}
+
+	balanceRelay := outbox.Relay{
+		DB:        transactionDB,
+		Topic:     model.TopicBalance,
+		Deliver:   outbox.BalanceUpdates(accounts),
+		Interval:  duration("BALANCE_SYNC_INTERVAL", 5*time.Second),
+		BatchSize: 100,
+	}
+
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go retentionJob.Run(jobCtx)
+	go balanceRelay.Run(jobCtx)
+
+	exchange := os.Getenv("EVENT_EXCHANGE")
+	if exchange == "" {
+		exchange = "transaction-events"
+	}
+	publisher := &messaging.Publisher{URL: os.Getenv("AMQP_URL"), Exchange: exchange}
+	defer publisher.Close()
+
+	eventRelay := outbox.Relay{
+		DB:        transactionDB,
+		Topic:     model.TopicEvent,
+		Deliver:   publisher.Publish,
+		Interval:  duration("EVENT_RELAY_INTERVAL", time.Second),
+		BatchSize: 100,
+	}
+	go eventRelay.Run(jobCtx)
+
+	if source := os.Getenv("JWKS_SOURCE"); source != "" {
+		jwks, err := util.NewJWKS(source)
+		if err != nil {
+			log.Fatalf("failed to load jwks: %v", err)
+		}
+		util.UseJWKS(jwks)
+		go jwks.Run(jobCtx, duration("JWKS_REFRESH_INTERVAL", time.Hour))
 	}
 
 	gin.SetMode(os.Getenv("GIN_MODE"))
 
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		if err := util.UseLogFormat(format); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	router := gin.Default()
+	router.Use(otelgin.Middleware(serviceName))
+	router.Use(util.Correlate)
 
-	msg := messaging.Messaging{}
-	err = msg.Init()
+	dropPolicy, err := messaging.ParseDropPolicy(os.Getenv("LOG_DROP_POLICY"))
 	if err != nil {
-		log.Printf("error with messaging: %s\n", err)
-	} else {
-		router.Use(msg.WriteInfo).Use(msg.WriteError)
-		defer msg.Close()
+		log.Fatal(err)
 	}
 
-	router.Use(util.CORS)
-	api := router.Group("api/v1").Use(util.ValidateToken)
-	{
-		api.POST("/transaction", transactionController.Create)
+	msg := messaging.NewMessaging(os.Getenv("AMQP_URL"), os.Getenv("EXCHANGE_QUEUE_NAME"),
+		integer("LOG_BUFFER_SIZE", 10000), integer("LOG_WORKERS", 2), integer("LOG_BATCH_SIZE", 100), dropPolicy)
+	msg.Start()
+	router.Use(msg.WriteInfo).Use(msg.WriteError)
 
-		api.GET("/types", transactionController.GetTypes)
-		api.GET("/transaction/:accountID/:type", transactionController.GetAll)
+	healthController := controller.HealthController{DB: mysqlDB, Messaging: msg}
+	router.GET("api/v1/health", healthController.Get)
 
-		api.DELETE("/transaction/:transactionID", transactionController.Delete)
-		api.DELETE("/transactions/:accountID", transactionController.DeleteForAccount)
-	}
+	router.Use(util.CORS)
+	api := router.Group("api/v1").Use(util.ValidateToken)
+	transactionController.Register(api)
 	router.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	srv := &http.Server{
@@ -134,6 +218,46 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("Shutdown() error: %v", err)
 	}
+	stopJobs()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := msg.Shutdown(ctx); err != nil {
+		log.Printf("messaging Shutdown() error: %v, %d log lines dropped", err, msg.Dropped())
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("tracing Shutdown() error: %v", err)
+	}
 
 	log.Println("shutting down")
 }
+
+// duration reads a time.Duration from the env variable, or returns def if it isn't set.
+func duration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return d
+}
+
+// integer reads an int from the env variable, or returns def if it isn't set.
+func integer(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		log.Fatalf("invalid %s: %v", key, value)
+	}
+	return i
+}
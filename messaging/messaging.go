@@ -2,92 +2,417 @@ package messaging
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/trace"
 	"log"
+	"main/tracing"
 	"main/util"
-	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+	// flushInterval bounds how long a line waits for its batch to fill up.
+	flushInterval = 200 * time.Millisecond
+)
+
+type line struct {
+	body        string
+	correlation string
+	// span of the request the line was written for, the parent of the publish span
+	span trace.SpanContext
+}
+
+// DropPolicy decides which line is dropped when the buffer is full.
+type DropPolicy string
+
+const (
+	// DropNewest drops the line being written.
+	DropNewest DropPolicy = "newest"
+	// DropOldest drops the oldest buffered line to make room for the new one.
+	DropOldest DropPolicy = "oldest"
+)
+
+// ParseDropPolicy returns the policy named by value, DropNewest if it is empty.
+func ParseDropPolicy(value string) (DropPolicy, error) {
+	switch DropPolicy(value) {
+	case "", DropNewest:
+		return DropNewest, nil
+	case DropOldest:
+		return DropOldest, nil
+	}
+	return "", fmt.Errorf("invalid log drop policy %q, supported: %q, %q", value, DropNewest, DropOldest)
+}
+
+// Messaging publishes log lines to a durable queue. The middleware only puts lines into a bounded
+// buffer, so a slow or unavailable broker never blocks a request. Worker goroutines take lines from the
+// buffer in batches and publish them with publisher confirms, each on its own channel of a shared
+// connection, which is reopened with backoff when it fails.
 type Messaging struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	queue   *amqp.Queue
+	URL string
+	// Name of the queue, and of the exchange it is published through
+	Name      string
+	Workers   int
+	BatchSize int
+	Policy    DropPolicy
+
+	buffer chan line
+	stop   chan struct{}
+	// flushCtx bounds publishing of the lines left when Shutdown is called
+	flushCtx context.Context
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	connected atomic.Bool
+
+	published atomic.Uint64
+	dropped   atomic.Uint64
 }
 
-func (receiver *Messaging) Init() error {
-	conn, err := amqp.Dial(os.Getenv("AMQP_URL"))
-	if err != nil {
-		return err
+func NewMessaging(url, name string, bufferSize, workers, batchSize int, policy DropPolicy) *Messaging {
+	if workers < 1 {
+		workers = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if policy == "" {
+		policy = DropNewest
 	}
-	receiver.conn = conn
 
-	ch, err := receiver.conn.Channel()
-	if err != nil {
-		return err
-	}
-	receiver.channel = ch
-
-	q, err := ch.QueueDeclare(
-		os.Getenv("EXCHANGE_QUEUE_NAME"),
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return err
+	return &Messaging{
+		URL:       url,
+		Name:      name,
+		Workers:   workers,
+		BatchSize: batchSize,
+		Policy:    policy,
+		buffer:    make(chan line, bufferSize),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Connected reports whether the broker connection is currently open.
+func (receiver *Messaging) Connected() bool {
+	return receiver.connected.Load()
+}
+
+// Published returns the number of lines confirmed by the broker.
+func (receiver *Messaging) Published() uint64 {
+	return receiver.published.Load()
+}
+
+// Dropped returns the number of lines dropped because the buffer was full or publishing had stopped.
+func (receiver *Messaging) Dropped() uint64 {
+	return receiver.dropped.Load()
+}
+
+// Start starts the workers.
+func (receiver *Messaging) Start() {
+	for i := 0; i < receiver.Workers; i++ {
+		receiver.wg.Add(1)
+		go receiver.work()
 	}
-	receiver.queue = &q
-	return nil
 }
 
-func (receiver *Messaging) Close() {
+// Shutdown stops accepting lines and waits until the workers have published the buffered ones, or
+// until ctx is done. Lines that couldn't be published by then are dropped.
+func (receiver *Messaging) Shutdown(ctx context.Context) error {
+	receiver.stopOnce.Do(func() {
+		receiver.flushCtx = ctx
+		close(receiver.stop)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		receiver.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
 	if receiver.conn != nil && !receiver.conn.IsClosed() {
-		err := receiver.conn.Close()
-		if err != nil {
+		if err := receiver.conn.Close(); err != nil {
 			log.Printf("conn close error: %v", err)
 		}
 	}
-	if receiver.channel != nil && !receiver.channel.IsClosed() {
-		err := receiver.channel.Close()
-		if err != nil {
-			log.Printf("channel close error: %v", err)
+	return err
+}
+
+func (receiver *Messaging) stopped() bool {
+	select {
+	case <-receiver.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// work publishes batches until Shutdown, then flushes what is left in the buffer.
+func (receiver *Messaging) work() {
+	defer receiver.wg.Done()
+
+	// Open the channel up front, so the connection state is known before the first line.
+	ch, err := receiver.channel()
+	if err != nil {
+		log.Printf("error with messaging: %v", err)
+	}
+	defer func() {
+		if ch != nil && !ch.IsClosed() {
+			_ = ch.Close()
+		}
+	}()
+
+	for {
+		batch, ok := receiver.batch()
+		if len(batch) > 0 {
+			ch = receiver.publish(ch, batch)
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// batch waits for lines and returns up to BatchSize of them. It returns false once Shutdown was called
+// and the buffer is empty.
+func (receiver *Messaging) batch() ([]line, bool) {
+	var batch []line
+
+	if !receiver.stopped() {
+		select {
+		case message := <-receiver.buffer:
+			batch = append(batch, message)
+		case <-receiver.stop:
+		}
+	}
+
+	timer := time.NewTimer(flushInterval)
+	defer timer.Stop()
+
+	for len(batch) < receiver.BatchSize {
+		// After Shutdown, take what is buffered without waiting for more.
+		if receiver.stopped() {
+			select {
+			case message := <-receiver.buffer:
+				batch = append(batch, message)
+				continue
+			default:
+				return batch, len(batch) > 0
+			}
+		}
+
+		select {
+		case message := <-receiver.buffer:
+			batch = append(batch, message)
+		case <-timer.C:
+			return batch, true
+		case <-receiver.stop:
+		}
+	}
+	return batch, true
+}
+
+// publish sends the batch on ch, opening a new channel when needed, and retries the lines that weren't
+// confirmed with backoff. It gives up only when the flush after Shutdown runs out of time. It returns
+// the channel to use for the next batch.
+func (receiver *Messaging) publish(ch *amqp.Channel, batch []line) *amqp.Channel {
+	delay := minReconnectDelay
+
+	for {
+		var err error
+		if ch == nil || ch.IsClosed() {
+			ch, err = receiver.channel()
+		}
+		if err == nil {
+			batch, err = receiver.write(ch, batch)
+		}
+		if err == nil {
+			return ch
+		}
+
+		log.Printf("error with messaging: %v, retrying in %s", err, delay)
+		if ch != nil && !ch.IsClosed() {
+			_ = ch.Close()
 		}
+		ch = nil
+
+		wait := time.NewTimer(delay)
+		select {
+		case <-wait.C:
+		case <-receiver.flushDone():
+			wait.Stop()
+			receiver.dropped.Add(uint64(len(batch)))
+			return nil
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// flushDone is closed once Shutdown has been called and its ctx is done.
+func (receiver *Messaging) flushDone() <-chan struct{} {
+	if !receiver.stopped() {
+		return nil
 	}
+	return receiver.flushCtx.Done()
 }
 
-func (receiver *Messaging) write(message string) error {
+// write publishes the batch and waits for the confirms. It returns the lines that weren't confirmed.
+func (receiver *Messaging) write(ch *amqp.Channel, batch []line) ([]line, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	return receiver.channel.PublishWithContext(ctx,
-		os.Getenv("EXCHANGE_QUEUE_NAME"),
-		receiver.queue.Name,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(message),
-		})
+	confirmations := make([]*amqp.DeferredConfirmation, 0, len(batch))
+	spans := make([]trace.Span, 0, len(batch))
+	for _, message := range batch {
+		spanCtx, span := startPublish(trace.ContextWithSpanContext(ctx, message.span), receiver.Name, receiver.Name)
+
+		confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx,
+			receiver.Name,
+			receiver.Name,
+			false,
+			false,
+			amqp.Publishing{
+				ContentType:   "text/plain",
+				CorrelationId: message.correlation,
+				Headers:       tracing.Inject(spanCtx),
+				Body:          []byte(message.body),
+			})
+		if err != nil {
+			tracing.End(span, err)
+			break
+		}
+		confirmations = append(confirmations, confirmation)
+		spans = append(spans, span)
+	}
+
+	var failed []line
+	for i, message := range batch {
+		if i >= len(confirmations) {
+			failed = append(failed, message)
+			continue
+		}
+
+		acked, err := confirmations[i].WaitContext(ctx)
+		if err == nil && !acked {
+			err = ErrNacked
+		}
+		tracing.End(spans[i], err)
+
+		if err != nil {
+			failed = append(failed, message)
+			continue
+		}
+		receiver.published.Add(1)
+	}
+
+	if len(failed) > 0 {
+		return failed, errors.New("log lines were not confirmed by the broker")
+	}
+	return nil, nil
 }
 
-func (receiver *Messaging) WriteInfo(context *gin.Context) {
-	err := receiver.write(util.Info(context))
+// channel opens a confirm-mode channel on the shared connection, dialing it first if needed.
+func (receiver *Messaging) channel() (*amqp.Channel, error) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	if receiver.conn == nil || receiver.conn.IsClosed() {
+		conn, err := amqp.Dial(receiver.URL)
+		if err != nil {
+			return nil, err
+		}
+		receiver.conn = conn
+		receiver.connected.Store(true)
+
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		go func() {
+			<-closed
+			receiver.connected.Store(false)
+		}()
+	}
+
+	ch, err := receiver.conn.Channel()
 	if err != nil {
-		log.Printf("error with messaging info: %s\n", err)
+		return nil, err
+	}
+
+	if _, err := ch.QueueDeclare(receiver.Name, true, false, false, false, nil); err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+	return ch, nil
+}
+
+// enqueue buffers the line without blocking. When the buffer is full, a line is dropped according to
+// the drop policy.
+func (receiver *Messaging) enqueue(message line) {
+	if receiver.stopped() {
+		receiver.dropped.Add(1)
+		return
+	}
+
+	select {
+	case receiver.buffer <- message:
+		return
+	default:
+	}
+
+	if receiver.Policy == DropOldest {
+		select {
+		case <-receiver.buffer:
+			receiver.dropped.Add(1)
+		default:
+			// A worker emptied a slot in the meantime.
+		}
+
+		select {
+		case receiver.buffer <- message:
+			return
+		default:
+			// Another writer took the freed slot.
+		}
 	}
+	receiver.dropped.Add(1)
+}
+
+// WriteInfo logs the request once the handler has completed.
+func (receiver *Messaging) WriteInfo(context *gin.Context) {
+	util.Begin(context)
+	context.Next()
+
+	receiver.enqueue(line{body: util.Info(context), correlation: util.CorrelationID(context),
+		span: trace.SpanContextFromContext(context.Request.Context())})
 }
 
 func (receiver *Messaging) WriteError(context *gin.Context) {
 	context.Next()
 
 	for _, err := range context.Errors {
-		returnerErr := receiver.write(util.Error(err.Error(), context))
-		if returnerErr != nil {
-			log.Printf("error with messaging error: %s\n", returnerErr)
-		}
+		receiver.enqueue(line{body: util.Error(err.Error(), context), correlation: util.CorrelationID(context),
+			span: trace.SpanContextFromContext(context.Request.Context())})
 	}
 }
@@ -0,0 +1,128 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"log"
+	"main/model"
+	"main/tracing"
+	"sync"
+	"time"
+)
+
+var ErrNacked = errors.New("message was not confirmed by the broker")
+
+// Publisher publishes outbox events to a topic exchange, with the event type as routing key. Every
+// publish waits for the broker's confirm. The connection is opened on first use and reopened after
+// a failure.
+type Publisher struct {
+	URL      string
+	Exchange string
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+func (receiver *Publisher) connect() error {
+	if receiver.channel != nil && !receiver.channel.IsClosed() {
+		return nil
+	}
+	receiver.close()
+
+	conn, err := amqp.Dial(receiver.URL)
+	if err != nil {
+		return err
+	}
+	receiver.conn = conn
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	receiver.channel = ch
+
+	if err := ch.ExchangeDeclare(receiver.Exchange, "topic", true, false, false, false, nil); err != nil {
+		return err
+	}
+	return ch.Confirm(false)
+}
+
+// Publish sends the event in the outbox message and waits until the broker confirms it. The trace
+// context of the publish is sent in the message headers.
+func (receiver *Publisher) Publish(ctx context.Context, message model.OutboxMessage) (err error) {
+	var event model.Event
+	if err := json.Unmarshal(message.Payload, &event); err != nil {
+		return err
+	}
+
+	ctx, span := startPublish(ctx, receiver.Exchange, event.Type)
+	span.SetAttributes(semconv.MessagingMessageID(event.ID))
+	defer func() { tracing.End(span, err) }()
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	if err := receiver.connect(); err != nil {
+		receiver.close()
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	confirmation, err := receiver.channel.PublishWithDeferredConfirmWithContext(ctx,
+		receiver.Exchange,
+		event.Type,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:   "application/json",
+			DeliveryMode:  amqp.Persistent,
+			MessageId:     event.ID,
+			CorrelationId: message.Correlation,
+			Timestamp:     event.Date,
+			Type:          event.Type,
+			Headers:       tracing.Inject(ctx),
+			Body:          message.Payload,
+		})
+	if err != nil {
+		receiver.close()
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		receiver.close()
+		return err
+	}
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
+
+func (receiver *Publisher) close() {
+	if receiver.channel != nil && !receiver.channel.IsClosed() {
+		if err := receiver.channel.Close(); err != nil {
+			log.Printf("channel close error: %v", err)
+		}
+	}
+	if receiver.conn != nil && !receiver.conn.IsClosed() {
+		if err := receiver.conn.Close(); err != nil {
+			log.Printf("conn close error: %v", err)
+		}
+	}
+	receiver.channel = nil
+	receiver.conn = nil
+}
+
+func (receiver *Publisher) Close() {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	receiver.close()
+}
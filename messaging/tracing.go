@@ -0,0 +1,22 @@
+package messaging
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("main/messaging")
+
+// startPublish starts the producer span of a message published to exchange with routingKey.
+func startPublish(ctx context.Context, exchange, routingKey string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, exchange+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("rabbitmq"),
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(exchange),
+			semconv.MessagingRabbitmqDestinationRoutingKey(routingKey),
+		))
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Account is the account as returned by the account API.
+type Account struct {
+	// Account UUID
+	PK string `json:"pk"`
+	// Owner (customer) UUID
+	CustomerID string `json:"customerID"`
+	// Account balance
+	Amount Amount `json:"amount"`
+	// Account currency, ISO 4217
+	Currency Currency `json:"currency"`
+	// Allowed overdraft in whole units
+	Limit int `json:"limit"`
+	// Date the account was closed, nil while it is open
+	CloseDate *time.Time `json:"closeDate"`
+	// When the account was requested from the account API. The balance is at least that recent.
+	FetchedAt time.Time `json:"-"`
+}
+
+// Overdraft returns the lowest balance the account is allowed to reach.
+func (receiver Account) Overdraft() Amount {
+	return -AmountFromUnits(int64(receiver.Limit))
+}
+
+// UnmarshalJSON decodes the balance with ExactAmount. The account API encodes it as a float, which is
+// accepted as long as it is a whole number of minor units of the account currency. Anything else is an
+// error, since rounding it would misstate the funds.
+func (receiver *Account) UnmarshalJSON(data []byte) error {
+	type account Account
+	var value struct {
+		account
+		Amount json.Number `json:"amount"`
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	*receiver = Account(value.account)
+	if value.Amount == "" {
+		return nil
+	}
+
+	amount, err := ExactAmount(value.Amount.String())
+	if err != nil {
+		return err
+	}
+	if !amount.ValidFor(receiver.Currency) {
+		return fmt.Errorf("%w: %s has more places than %s uses", ErrInvalidAmount, value.Amount, receiver.Currency)
+	}
+	receiver.Amount = amount
+	return nil
+}
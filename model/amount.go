@@ -0,0 +1,196 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is an exact money amount in minor units (cents), matching the DECIMAL(10, 2) columns. How many
+// of the two places a currency uses is given by Currency.Places.
+type Amount int64
+
+const (
+	amountPlaces = 2
+	amountScale  = 100
+)
+
+// MaxAmount is the largest amount the DECIMAL(10, 2) columns hold.
+const MaxAmount Amount = 99_999_999_99
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount, expected a number with at most two decimal places")
+	ErrAmountOutOfRange = errors.New("amount out of range")
+)
+
+// AmountFromUnits returns the amount for a whole number of major units.
+func AmountFromUnits(units int64) Amount {
+	return Amount(units * amountScale)
+}
+
+// ParseAmount parses a decimal string such as "17.24". More than two fraction digits are rejected
+// instead of being rounded. Use ValidFor to check the amount against the places of its currency.
+func ParseAmount(value string) (Amount, error) {
+	amount, err := parseDecimal(value, amountPlaces)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	return Amount(amount), nil
+}
+
+// ExactAmount parses a JSON number in any notation, such as a float64 encoded by another service as
+// "17.24" or "1e+21". A value that isn't a whole number of cents is rejected, never rounded.
+func ExactAmount(value string) (Amount, error) {
+	number, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+
+	number.Mul(number, new(big.Rat).SetInt64(amountScale))
+	if !number.IsInt() || !number.Num().IsInt64() {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	return Amount(number.Num().Int64()), nil
+}
+
+// roundHalfEven returns numerator / denominator rounded half to even.
+func roundHalfEven(numerator, denominator *big.Int) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+
+	twice := new(big.Int).Abs(remainder)
+	twice.Mul(twice, big.NewInt(2))
+	switch cmp := twice.Cmp(new(big.Int).Abs(denominator)); {
+	case cmp > 0, cmp == 0 && quotient.Bit(0) == 1:
+		if (numerator.Sign() < 0) != (denominator.Sign() < 0) {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return quotient
+}
+
+// ValidFor reports whether the amount has no more fraction digits than currency uses.
+func (receiver Amount) ValidFor(currency Currency) bool {
+	return int64(receiver)%int64(math.Pow10(amountPlaces-currency.Places())) == 0
+}
+
+// parseDecimal parses value into an integer scaled by 10^places, rejecting values with more fraction digits.
+func parseDecimal(value string, places int) (int64, error) {
+	s := value
+	negative := strings.HasPrefix(s, "-")
+	if negative {
+		s = s[1:]
+	}
+
+	whole, fraction, hasDot := strings.Cut(s, ".")
+	if whole == "" || (hasDot && fraction == "") || len(fraction) > places || !isDigits(whole) ||
+		!isDigits(fraction) {
+		return 0, errors.New("invalid decimal")
+	}
+
+	scale := int64(math.Pow10(places))
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/scale-1 {
+		return 0, errors.New("decimal out of range")
+	}
+
+	fraction += strings.Repeat("0", places-len(fraction))
+	minor := int64(0)
+	if fraction != "" {
+		minor, _ = strconv.ParseInt(fraction, 10, 64)
+	}
+
+	result := units*scale + minor
+	if negative {
+		result = -result
+	}
+	return result, nil
+}
+
+// trimDecimal drops trailing zeros past places, which MySQL adds to results of DECIMAL arithmetic.
+func trimDecimal(value string, places int) string {
+	whole, fraction, ok := strings.Cut(value, ".")
+	if !ok || len(fraction) <= places {
+		return value
+	}
+
+	trimmed := strings.TrimRight(fraction, "0")
+	if len(trimmed) > places {
+		return value
+	}
+	return whole + "." + fraction[:places]
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (receiver Amount) String() string {
+	value := int64(receiver)
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/amountScale, value%amountScale)
+}
+
+func (receiver Amount) MarshalJSON() ([]byte, error) {
+	return []byte(receiver.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a string containing a number.
+func (receiver *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	amount, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*receiver = amount
+	return nil
+}
+
+func (receiver *Amount) Scan(src any) error {
+	switch value := src.(type) {
+	case []byte:
+		return receiver.scanString(string(value))
+	case string:
+		return receiver.scanString(value)
+	case int64:
+		*receiver = AmountFromUnits(value)
+		return nil
+	default:
+		return fmt.Errorf("unsupported amount type: %T", src)
+	}
+}
+
+func (receiver *Amount) scanString(value string) error {
+	amount, err := ParseAmount(trimDecimal(value, amountPlaces))
+	if err != nil {
+		return err
+	}
+	*receiver = amount
+	return nil
+}
+
+func (receiver Amount) Value() (driver.Value, error) {
+	return receiver.String(), nil
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"errors"
+	"strings"
+)
+
+// Currency is an ISO 4217 alphabetic currency code.
+type Currency string
+
+var ErrInvalidCurrency = errors.New("invalid currency, expected an ISO 4217 code")
+
+var currencies = map[Currency]struct{}{}
+
+func init() {
+	for _, code := range strings.Fields("AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND " +
+		"BOB BRL BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR " +
+		"FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS " +
+		"KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN " +
+		"MYR MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG " +
+		"SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS " +
+		"VES VND VUV WST XAF XCD XOF XPF YER ZAR ZMW ZWL") {
+		currencies[Currency(code)] = struct{}{}
+	}
+}
+
+// exponents lists the currencies that don't use two decimal places, by ISO 4217 minor unit.
+var exponents = map[Currency]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0, "RWF": 0,
+	"UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+func (receiver Currency) IsValid() bool {
+	_, ok := currencies[receiver]
+	return ok
+}
+
+// Exponent returns the number of decimal places of the currency's minor unit.
+func (receiver Currency) Exponent() int {
+	if exponent, ok := exponents[receiver]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// Places returns the number of decimal places amounts in the currency may use: its exponent, capped at
+// the two places of Amount.
+func (receiver Currency) Places() int {
+	if exponent := receiver.Exponent(); exponent < amountPlaces {
+		return exponent
+	}
+	return amountPlaces
+}
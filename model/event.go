@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+)
+
+// Domain event types, also used as AMQP routing keys.
+const (
+	EventTransactionCreated  = "TransactionCreated"
+	EventTransactionReversed = "TransactionReversed"
+	EventTransactionDeleted  = "TransactionDeleted"
+)
+
+// Event announces a change to a transaction to other services.
+type Event struct {
+	// Event UUID, lets consumers drop duplicates
+	ID string `json:"id" example:"7f0e7a5c-3c8e-4d55-9a0e-2f6b0f1d9c11"`
+	// Event type: 'TransactionCreated', 'TransactionReversed' or 'TransactionDeleted'
+	Type string `json:"type" example:"TransactionCreated"`
+	// Event date
+	Date time.Time `json:"date" example:"2022-12-21T08:45:12+01:00"`
+	// Transaction UUID
+	TransactionID string `json:"transactionID" example:"4a5ed2e0-5cdb-4f9e-96e3-ecc372ba4f0c"`
+	// Created transaction, or the reversal for TransactionReversed
+	Transaction *Transaction `json:"transaction,omitempty"`
+	// Deletion details for TransactionDeleted
+	Deletion *Deletion `json:"deletion,omitempty"`
+} //@name Event
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last transaction of a page, in date then ID order.
+type Cursor struct {
+	Date time.Time
+	ID   string
+}
+
+func NewCursor(transaction Transaction) Cursor {
+	return Cursor{Date: transaction.Date, ID: transaction.ID}
+}
+
+func (receiver Cursor) GetDate() string {
+	return receiver.Date.Format("2006-01-02 15:04:05")
+}
+
+func (receiver Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(receiver.GetDate() + "|" + receiver.ID))
+}
+
+func DecodeCursor(value string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	date, id, ok := strings.Cut(string(data), "|")
+	if !ok || id == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	parsed, err := time.Parse("2006-01-02 15:04:05", date)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Date: parsed, ID: id}, nil
+}
+
+// TransactionFilter narrows down and pages the transactions of an account. Zero values don't filter.
+type TransactionFilter struct {
+	From         time.Time
+	To           time.Time
+	MinAmount    *Amount
+	MaxAmount    *Amount
+	Type         int
+	Counterparty string
+	Limit        int
+	After        *Cursor
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+type IdempotencyKey struct {
+	// Subject of the caller that sent the key, keys of different callers never collide
+	Subject string
+	// Value of the Idempotency-Key header
+	Key string
+	// SHA-256 of the request body
+	RequestHash string
+	// HTTP status of the original response
+	StatusCode int
+	// Body of the original response
+	Response []byte
+	// Time after which the key can be reused
+	ExpiresAt time.Time
+}
+
+func (receiver IdempotencyKey) GetExpiresAt() string {
+	return receiver.ExpiresAt.Format("2006-01-02 15:04:05")
+}
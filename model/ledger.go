@@ -0,0 +1,255 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+type Direction string
+
+const (
+	Debit  Direction = "debit"
+	Credit Direction = "credit"
+)
+
+type Posting struct {
+	// Account UUID
+	AccountID string `json:"accountID" example:"5d84ca00-c079-4577-9560-e1014086affe"`
+	// Posting direction: 'debit' or 'credit'
+	Direction Direction `json:"direction" example:"debit"`
+	// Posting amount
+	Amount Amount `json:"amount" example:"17.24" swaggertype:"number"`
+	// Posting currency, ISO 4217
+	Currency Currency `json:"currency" example:"EUR" swaggertype:"string"`
+} //@name Posting
+
+type JournalEntry struct {
+	// JournalEntry UUID
+	ID string `json:"id" example:"0b0c8a3e-4b1f-4b8e-9d55-0f1f3c1e6a7d"`
+	// Transaction UUID
+	TransactionID string `json:"transactionID" example:"4a5ed2e0-5cdb-4f9e-96e3-ecc372ba4f0c"`
+	// JournalEntry date
+	Date time.Time `json:"date" example:"2022-12-21T08:45:12+01:00"`
+	// JournalEntry description
+	Description string `json:"description" example:"transfer"`
+	// JournalEntry postings
+	Postings []Posting `json:"postings"`
+} //@name JournalEntry
+
+func (receiver JournalEntry) GetDate() string {
+	return receiver.Date.Format("2006-01-02 15:04:05")
+}
+
+// IsBalanced reports whether, for every currency, the sum of debits equals the sum of credits.
+func (receiver JournalEntry) IsBalanced() bool {
+	if len(receiver.Postings) < 2 {
+		return false
+	}
+
+	totals := map[Currency]Amount{}
+	for _, posting := range receiver.Postings {
+		switch posting.Direction {
+		case Debit:
+			totals[posting.Currency] += posting.Amount
+		case Credit:
+			totals[posting.Currency] -= posting.Amount
+		default:
+			return false
+		}
+	}
+
+	for _, total := range totals {
+		if total != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// FXAccount is the clearing account through which amounts in currency are converted.
+func FXAccount(currency Currency) string {
+	return "fx-" + string(currency)
+}
+
+// HoldAccount is the clearing account holding the authorized amounts in currency until they are
+// captured or released.
+func HoldAccount(currency Currency) string {
+	return "hold-" + string(currency)
+}
+
+// IsInternalAccount reports whether the account is an FX or hold clearing account, which only exists
+// in this ledger.
+func IsInternalAccount(accountID string) bool {
+	return strings.HasPrefix(accountID, "fx-") || strings.HasPrefix(accountID, "hold-")
+}
+
+// NewTransferEntry debits the sender and credits the recipient of the transaction. A conversion
+// goes through the FX clearing accounts, so each currency stays balanced.
+func NewTransferEntry(id string, transaction Transaction, description string) JournalEntry {
+	return newEntry(id, transaction, transaction.SenderID, description)
+}
+
+// NewHoldEntry moves the amount of an authorized transaction from the sender to the hold account.
+func NewHoldEntry(id string, transaction Transaction, description string) JournalEntry {
+	return JournalEntry{
+		ID:            id,
+		TransactionID: transaction.ID,
+		Date:          transaction.Date,
+		Description:   description,
+		Postings: []Posting{
+			{AccountID: transaction.SenderID, Direction: Debit, Amount: transaction.Amount,
+				Currency: transaction.Currency},
+			{AccountID: HoldAccount(transaction.Currency), Direction: Credit, Amount: transaction.Amount,
+				Currency: transaction.Currency},
+		},
+	}
+}
+
+// NewCaptureEntry moves the held amount of the transaction to the recipient.
+func NewCaptureEntry(id string, transaction Transaction, description string) JournalEntry {
+	return newEntry(id, transaction, HoldAccount(transaction.Currency), description)
+}
+
+func newEntry(id string, transaction Transaction, from string, description string) JournalEntry {
+	entry := JournalEntry{
+		ID:            id,
+		TransactionID: transaction.ID,
+		Date:          transaction.Date,
+		Description:   description,
+	}
+
+	if transaction.Currency == transaction.RecipientCurrency {
+		entry.Postings = []Posting{
+			{AccountID: from, Direction: Debit, Amount: transaction.Amount, Currency: transaction.Currency},
+			{AccountID: transaction.RecipientID, Direction: Credit, Amount: transaction.RecipientAmount,
+				Currency: transaction.RecipientCurrency},
+		}
+		return entry
+	}
+
+	entry.Postings = []Posting{
+		{AccountID: from, Direction: Debit, Amount: transaction.Amount, Currency: transaction.Currency},
+		{AccountID: FXAccount(transaction.Currency), Direction: Credit, Amount: transaction.Amount,
+			Currency: transaction.Currency},
+		{AccountID: FXAccount(transaction.RecipientCurrency), Direction: Debit, Amount: transaction.RecipientAmount,
+			Currency: transaction.RecipientCurrency},
+		{AccountID: transaction.RecipientID, Direction: Credit, Amount: transaction.RecipientAmount,
+			Currency: transaction.RecipientCurrency},
+	}
+	return entry
+}
+
+// CreatedEntry returns the entry posted when the transaction is created. An authorized transaction
+// only holds its amount, a settled one moves it to the recipient.
+func (receiver Transaction) CreatedEntry(id string, description string) JournalEntry {
+	if receiver.Status == Authorized {
+		return NewHoldEntry(id, receiver, description)
+	}
+	return NewTransferEntry(id, receiver, description)
+}
+
+// TransitionEntry returns the entry posted when the transaction moves from its current status to the
+// given one, if any. Capturing an authorized transaction moves the held amount to the recipient,
+// failing or cancelling it releases the amount to the sender.
+func (receiver Transaction) TransitionEntry(id string, to Status, date time.Time) (JournalEntry, bool) {
+	if receiver.Status != Authorized {
+		return JournalEntry{}, false
+	}
+
+	var entry JournalEntry
+	switch to {
+	case Settled:
+		entry = NewCaptureEntry(id, receiver, "capture")
+	case Failed, Cancelled:
+		entry = NewHoldEntry("", receiver, "").Inverse(id, date, string(to))
+	default:
+		return JournalEntry{}, false
+	}
+	entry.Date = date
+	return entry, true
+}
+
+// Inverse returns an entry with every posting of the receiver in the opposite direction.
+func (receiver JournalEntry) Inverse(id string, date time.Time, description string) JournalEntry {
+	entry := JournalEntry{
+		ID:            id,
+		TransactionID: receiver.TransactionID,
+		Date:          date,
+		Description:   description,
+	}
+
+	for _, posting := range receiver.Postings {
+		if posting.Direction == Debit {
+			posting.Direction = Credit
+		} else {
+			posting.Direction = Debit
+		}
+		entry.Postings = append(entry.Postings, posting)
+	}
+	return entry
+}
+
+// BalanceUpdate tells the account service how much an entry moved on one of its accounts.
+type BalanceUpdate struct {
+	// Unique per entry and account, used by the account service to drop duplicates
+	ID string `json:"id" example:"0b0c8a3e-4b1f-4b8e-9d55-0f1f3c1e6a7d/5d84ca00-c079-4577-9560-e1014086affe"`
+	// Account UUID
+	AccountID string `json:"accountID" example:"5d84ca00-c079-4577-9560-e1014086affe"`
+	// Transaction UUID
+	TransactionID string `json:"transactionID" example:"4a5ed2e0-5cdb-4f9e-96e3-ecc372ba4f0c"`
+	// JournalEntry UUID
+	EntryID string `json:"entryID" example:"0b0c8a3e-4b1f-4b8e-9d55-0f1f3c1e6a7d"`
+	// Credits minus debits of the entry on the account
+	Amount Amount `json:"amount" example:"-17.24" swaggertype:"number"`
+	// Amount currency, ISO 4217
+	Currency Currency `json:"currency" example:"EUR" swaggertype:"string"`
+	// JournalEntry date
+	Date time.Time `json:"date" example:"2022-12-21T08:45:12+01:00"`
+} //@name BalanceUpdate
+
+// BalanceUpdates returns one update per account of the entry, leaving out the clearing accounts.
+func (receiver JournalEntry) BalanceUpdates() []BalanceUpdate {
+	var updates []BalanceUpdate
+	index := map[string]int{}
+
+	for _, posting := range receiver.Postings {
+		if IsInternalAccount(posting.AccountID) {
+			continue
+		}
+
+		amount := posting.Amount
+		if posting.Direction == Debit {
+			amount = -amount
+		}
+
+		if i, ok := index[posting.AccountID]; ok {
+			updates[i].Amount += amount
+			continue
+		}
+
+		index[posting.AccountID] = len(updates)
+		updates = append(updates, BalanceUpdate{
+			ID:            receiver.ID + "/" + posting.AccountID,
+			AccountID:     posting.AccountID,
+			TransactionID: receiver.TransactionID,
+			EntryID:       receiver.ID,
+			Amount:        amount,
+			Currency:      posting.Currency,
+			Date:          receiver.Date,
+		})
+	}
+	return updates
+}
+
+type Balance struct {
+	// Account UUID
+	AccountID string `json:"accountID" example:"5d84ca00-c079-4577-9560-e1014086affe"`
+	// Currency of the postings, ISO 4217
+	Currency Currency `json:"currency" example:"EUR" swaggertype:"string"`
+	// Sum of all debits
+	Debit Amount `json:"debit" example:"120.50" swaggertype:"number"`
+	// Sum of all credits
+	Credit Amount `json:"credit" example:"200.00" swaggertype:"number"`
+	// Credits minus debits
+	Balance Amount `json:"balance" example:"79.50" swaggertype:"number"`
+} //@name Balance
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+)
+
+// Outbox topics.
+const (
+	// TopicBalance messages carry a BalanceUpdate for the account service.
+	TopicBalance = "balance"
+	// TopicEvent messages carry an Event for the event exchange.
+	TopicEvent = "event"
+)
+
+// OutboxMessage is written in the same SQL transaction as the change it announces, and delivered
+// by a relay after the commit.
+type OutboxMessage struct {
+	ID    int64
+	Topic string
+	// Key lets the receiver drop duplicate deliveries
+	Key string
+	// Correlation ID of the request that caused the message
+	Correlation string
+	Payload     []byte
+	CreatedAt   time.Time
+	Attempts    int
+}
+
+func (receiver OutboxMessage) GetCreatedAt() string {
+	return receiver.CreatedAt.Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Rate is an exchange rate with eight decimal places, matching the DECIMAL(18, 8) columns.
+type Rate int64
+
+const rateScale = 100_000_000
+
+// RateOne is the rate between equal currencies.
+const RateOne Rate = rateScale
+
+var ErrInvalidRate = errors.New("invalid rate, expected a positive number with at most eight decimal places")
+
+func ParseRate(value string) (Rate, error) {
+	rate, err := parseDecimal(value, 8)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidRate, value)
+	}
+	return Rate(rate), nil
+}
+
+// Convert returns amount multiplied by the rate, in currency to. The exact product is rounded half to
+// even to the minor unit of to, which is the only rounding applied to money. A product larger than
+// MaxAmount is an error.
+func (receiver Rate) Convert(amount Amount, to Currency) (Amount, error) {
+	step := big.NewInt(int64(math.Pow10(amountPlaces - to.Places())))
+	product := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(int64(receiver)))
+
+	rounded := roundHalfEven(product, new(big.Int).Mul(big.NewInt(rateScale), step))
+	rounded.Mul(rounded, step)
+	if !rounded.IsInt64() || new(big.Int).Abs(rounded).Cmp(big.NewInt(int64(MaxAmount))) > 0 {
+		return 0, fmt.Errorf("%w: %s at %s", ErrAmountOutOfRange, amount, receiver)
+	}
+	return Amount(rounded.Int64()), nil
+}
+
+func (receiver Rate) String() string {
+	return fmt.Sprintf("%d.%08d", int64(receiver)/rateScale, int64(receiver)%rateScale)
+}
+
+func (receiver Rate) MarshalJSON() ([]byte, error) {
+	return []byte(receiver.String()), nil
+}
+
+func (receiver *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	rate, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*receiver = rate
+	return nil
+}
+
+func (receiver *Rate) Scan(src any) error {
+	var value string
+	switch v := src.(type) {
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	default:
+		return fmt.Errorf("unsupported rate type: %T", src)
+	}
+
+	rate, err := ParseRate(trimDecimal(value, 8))
+	if err != nil {
+		return err
+	}
+	*receiver = rate
+	return nil
+}
+
+func (receiver Rate) Value() (driver.Value, error) {
+	return receiver.String(), nil
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+)
+
+type Status string
+
+const (
+	Pending    Status = "pending"
+	Authorized Status = "authorized"
+	Settled    Status = "settled"
+	Failed     Status = "failed"
+	Cancelled  Status = "cancelled"
+)
+
+var transitions = map[Status][]Status{
+	Pending:    {Authorized, Failed, Cancelled},
+	Authorized: {Settled, Failed, Cancelled},
+}
+
+func (receiver Status) IsValid() bool {
+	switch receiver {
+	case Pending, Authorized, Settled, Failed, Cancelled:
+		return true
+	}
+	return false
+}
+
+func (receiver Status) CanTransition(to Status) bool {
+	for _, status := range transitions[receiver] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether the transaction can't move on from the status anymore.
+func (receiver Status) IsFinal() bool {
+	return len(transitions[receiver]) == 0
+}
+
+// CreatedStatuses returns the statuses a new transaction goes through once its funds are reserved.
+// Types requiring a capture, like card payments, stop at authorized and have to be settled later.
+func CreatedStatuses(transactionType TransactionType) []Status {
+	if transactionType.RequiresCapture {
+		return []Status{Pending, Authorized}
+	}
+	return []Status{Pending, Authorized, Settled}
+}
+
+type StatusChange struct {
+	// Status the transaction moved to
+	Status Status `json:"status" example:"authorized" swaggertype:"string"`
+	// Date of the change
+	Date time.Time `json:"date" example:"2022-12-21T08:45:12+01:00"`
+	// Subject of the token that made the change
+	By string `json:"by,omitempty" example:"495d45e9-644c-40b8-94e8-103cad128331"`
+	// Reason for the change
+	Reason string `json:"reason,omitempty" example:"captured"`
+} //@name StatusChange
+
+func (receiver StatusChange) GetDate() string {
+	return receiver.Date.Format("2006-01-02 15:04:05")
+}
+
+// NewHistory returns the changes for a transaction that went through statuses at once.
+func NewHistory(date time.Time, by string, statuses ...Status) []StatusChange {
+	history := make([]StatusChange, 0, len(statuses))
+	for _, status := range statuses {
+		history = append(history, StatusChange{Status: status, Date: date, By: by})
+	}
+	return history
+}
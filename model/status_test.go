@@ -0,0 +1,18 @@
+package model
+
+import "testing"
+
+func TestStatusIsFinal(t *testing.T) {
+	tests := map[Status]bool{
+		Pending:    false,
+		Authorized: false,
+		Settled:    true,
+		Failed:     true,
+		Cancelled:  true,
+	}
+	for status, want := range tests {
+		if got := status.IsFinal(); got != want {
+			t.Errorf("%s.IsFinal() = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -12,20 +12,97 @@ type Transaction struct {
 	// Recipient account UUID
 	RecipientID string `json:"recipientID" example:"8cca0453-8e84-4f3b-aa40-7fc9cd162a34"`
 	// Transaction amount
-	Amount float64 `json:"amount" example:"17.24"`
+	Amount Amount `json:"amount" example:"17.24" swaggertype:"number"`
+	// Currency of the amount, ISO 4217
+	Currency Currency `json:"currency" example:"EUR" swaggertype:"string"`
+	// Amount credited to the recipient
+	RecipientAmount Amount `json:"recipientAmount" example:"18.47" swaggertype:"number"`
+	// Currency of the recipient amount, ISO 4217
+	RecipientCurrency Currency `json:"recipientCurrency" example:"USD" swaggertype:"string"`
+	// Exchange rate applied to the amount, a reversal keeps the rate of the transaction it reverses
+	Rate Rate `json:"rate" example:"1.07120000" swaggertype:"number"`
+	// UUID of the transaction this transaction reverses
+	ReversalOf string `json:"reversalOf,omitempty" example:"9b2f6c1e-3f0a-4d7e-8f57-2c8a4e6d1b90"`
+	// Amount already refunded by reversals, in the currency of the amount
+	Refunded Amount `json:"refunded" example:"5.00" swaggertype:"number"`
+	// Amount already paid back by reversals, in the currency of the recipient amount
+	PaidBack Amount `json:"paidBack" example:"5.36" swaggertype:"number"`
+	// Date the transaction was soft-deleted
+	DeletedAt *time.Time `json:"deletedAt,omitempty" example:"2022-12-22T10:12:40+01:00"`
+	// Subject of the token that deleted the transaction
+	DeletedBy string `json:"deletedBy,omitempty" example:"495d45e9-644c-40b8-94e8-103cad128331"`
+	// Reason for the deletion
+	DeleteReason string `json:"deleteReason,omitempty" example:"duplicate"`
+	// Date the retention job removed the deletion details and status history
+	PurgedAt *time.Time `json:"purgedAt,omitempty" example:"2032-12-22T10:12:40+01:00"`
 	// Transaction date
 	Date time.Time `json:"date" example:"2022-12-21T08:45:12+01:00"`
 	// Transaction type
 	Type TransactionType `json:"type"`
+	// Transaction status: 'pending', 'authorized', 'settled', 'failed' or 'cancelled'
+	Status Status `json:"status" example:"settled" swaggertype:"string"`
+	// Status changes, oldest first
+	History []StatusChange `json:"history,omitempty"`
 } //@name Transaction
 
 func (receiver Transaction) GetDate() string {
 	return receiver.Date.Format("2006-01-02 15:04:05")
 }
 
+// Reversal returns the compensating transaction that sends amount, given in the currency of the
+// receiver's amount, from the recipient back to the sender at the original rate. A partial refund is
+// converted, but never pays back more than is left. The refund of everything that is left pays back
+// exactly what is left, so the reversals add up to the recipient amount however their conversions
+// were rounded.
+func (receiver Transaction) Reversal(id string, amount Amount, date time.Time, by string) (Transaction, error) {
+	left := receiver.RecipientAmount - receiver.PaidBack
+	payback := left
+
+	if amount != receiver.Amount-receiver.Refunded {
+		converted, err := receiver.Rate.Convert(amount, receiver.RecipientCurrency)
+		if err != nil {
+			return Transaction{}, err
+		}
+		if converted < left {
+			payback = converted
+		}
+	}
+
+	return Transaction{
+		ID:                id,
+		SenderID:          receiver.RecipientID,
+		RecipientID:       receiver.SenderID,
+		Amount:            payback,
+		Currency:          receiver.RecipientCurrency,
+		RecipientAmount:   amount,
+		RecipientCurrency: receiver.Currency,
+		Rate:              receiver.Rate,
+		ReversalOf:        receiver.ID,
+		Date:              date,
+		Type:              receiver.Type,
+		Status:            Settled,
+		History:           NewHistory(date, by, Pending, Authorized, Settled),
+	}, nil
+}
+
+// Deletion records who soft-deleted a transaction, when and why.
+type Deletion struct {
+	Date   time.Time `json:"date"`
+	By     string    `json:"by"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func (receiver Deletion) GetDate() string {
+	return receiver.Date.Format("2006-01-02 15:04:05")
+}
+
 type TransactionType struct {
 	// TransactionType ID
 	ID int `json:"id" example:"1"`
 	// TransactionType description
 	Type string `json:"type,omitempty" example:"card-payment"`
+	// Inactive types can't be used for new transactions
+	Active bool `json:"active" example:"true"`
+	// Transactions of the type are only authorized when created, and settled when captured
+	RequiresCapture bool `json:"requiresCapture" example:"true"`
 } //@name TransactionType
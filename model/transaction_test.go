@@ -0,0 +1,67 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReversalPartialRefunds(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   Amount
+		rate     string
+		refunds  []Amount
+		currency Currency
+	}{
+		{"thirds", AmountFromUnits(10), "1.07125000", []Amount{333, 333, 334}, "USD"},
+		{"rounding up", 3, "1.50000000", []Amount{1, 1, 1}, "USD"},
+		{"rounding down", 100, "1.00500000", []Amount{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, "USD"},
+		{"zero places", AmountFromUnits(100), "156.78900000", []Amount{3333, 3333, 3334}, "JPY"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rate, err := ParseRate(test.rate)
+			if err != nil {
+				t.Fatalf("ParseRate() error: %v", err)
+			}
+
+			recipientAmount, err := rate.Convert(test.amount, test.currency)
+			if err != nil {
+				t.Fatalf("Convert() error: %v", err)
+			}
+
+			original := Transaction{
+				ID:                "4a5ed2e0-5cdb-4f9e-96e3-ecc372ba4f0c",
+				Amount:            test.amount,
+				Currency:          "EUR",
+				RecipientAmount:   recipientAmount,
+				RecipientCurrency: test.currency,
+				Rate:              rate,
+			}
+
+			for _, refund := range test.refunds {
+				reversal, err := original.Reversal("", refund, time.Now(), "")
+				if err != nil {
+					t.Fatalf("Reversal() error: %v", err)
+				}
+				if reversal.Amount < 0 || reversal.RecipientAmount != refund {
+					t.Fatalf("reversal pays back %v for a refund of %v", reversal.Amount, reversal.RecipientAmount)
+				}
+
+				original.Refunded += reversal.RecipientAmount
+				original.PaidBack += reversal.Amount
+				if original.PaidBack > original.RecipientAmount {
+					t.Fatalf("paid back %v of %v", original.PaidBack, original.RecipientAmount)
+				}
+			}
+
+			if original.Refunded != original.Amount {
+				t.Errorf("refunded %v, want %v", original.Refunded, original.Amount)
+			}
+			if original.PaidBack != original.RecipientAmount {
+				t.Errorf("paid back %v, want %v", original.PaidBack, original.RecipientAmount)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"main/db"
+	"main/model"
+	"main/util"
+	"math/rand"
+	"time"
+)
+
+const (
+	// lease is how long a claimed message is hidden from other relays.
+	lease      = time.Minute
+	maxBackoff = time.Hour
+)
+
+// Deliver sends the message to its destination. The message counts as delivered once it returns nil.
+type Deliver func(ctx context.Context, message model.OutboxMessage) error
+
+// Relay delivers outbox messages of one topic after their SQL transaction has committed. Failed
+// deliveries are retried with exponential backoff until they succeed.
+type Relay struct {
+	DB        *db.TransactionDB
+	Topic     string
+	Deliver   Deliver
+	Interval  time.Duration
+	BatchSize int
+}
+
+func (receiver Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(receiver.Interval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch means there may be more due messages.
+		for ctx.Err() == nil && receiver.relay(ctx) == receiver.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay delivers one batch and returns its size.
+func (receiver Relay) relay(ctx context.Context) int {
+	messages, err := receiver.DB.ClaimOutbox(ctx, receiver.Topic, receiver.BatchSize, lease)
+	if err != nil {
+		log.Printf("outbox %s claim error: %v", receiver.Topic, err)
+		return 0
+	}
+
+	for _, message := range messages {
+		if err := receiver.Deliver(ctx, message); err != nil {
+			log.Printf("outbox %s delivery of %s failed: %v", receiver.Topic, message.Key, err)
+			if err := receiver.DB.Failed(ctx, message, time.Now().Add(backoff(message.Attempts)), err); err != nil {
+				log.Printf("outbox %s error: %v", receiver.Topic, err)
+			}
+			continue
+		}
+
+		if err := receiver.DB.Delivered(ctx, message); err != nil {
+			log.Printf("outbox %s error: %v", receiver.Topic, err)
+		}
+	}
+	return len(messages)
+}
+
+// backoff returns the delay before the next attempt, doubling from a second up to maxBackoff, with jitter.
+func backoff(attempts int) time.Duration {
+	delay := maxBackoff
+	if attempts < 12 && time.Second<<attempts < maxBackoff {
+		delay = time.Second << attempts
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// BalanceUpdates delivers balance updates to the account service.
+func BalanceUpdates(accounts *util.AccountClient) Deliver {
+	return func(ctx context.Context, message model.OutboxMessage) error {
+		var update model.BalanceUpdate
+		if err := json.Unmarshal(message.Payload, &update); err != nil {
+			return err
+		}
+		return accounts.UpdateBalance(ctx, update, message.Correlation)
+	}
+}
@@ -0,0 +1,77 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"main/model"
+	"time"
+)
+
+type TransactionFilter struct {
+	// Only transactions on or after this date, RFC 3339
+	From time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Only transactions before this date, RFC 3339
+	To time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Minimum amount
+	MinAmount string `form:"minAmount"`
+	// Maximum amount
+	MaxAmount string `form:"maxAmount"`
+	// Transaction type ID
+	Type int `form:"typeID"`
+	// Account UUID of the other side of the transaction
+	Counterparty string `form:"counterparty"`
+	// Page size
+	Limit int `form:"limit"`
+	// Cursor from the previous page
+	Cursor string `form:"cursor"`
+}
+
+func (receiver TransactionFilter) Parse() (model.TransactionFilter, error) {
+	filter := model.TransactionFilter{
+		From:         receiver.From,
+		To:           receiver.To,
+		Type:         receiver.Type,
+		Counterparty: receiver.Counterparty,
+		Limit:        receiver.Limit,
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return model.TransactionFilter{}, errors.New("invalid date range, from must be before to")
+	}
+
+	if receiver.MinAmount != "" {
+		amount, err := model.ParseAmount(receiver.MinAmount)
+		if err != nil {
+			return model.TransactionFilter{}, err
+		}
+		filter.MinAmount = &amount
+	}
+
+	if receiver.MaxAmount != "" {
+		amount, err := model.ParseAmount(receiver.MaxAmount)
+		if err != nil {
+			return model.TransactionFilter{}, err
+		}
+		filter.MaxAmount = &amount
+	}
+
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return model.TransactionFilter{}, errors.New("invalid amount range, minAmount is greater than maxAmount")
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = model.DefaultPageSize
+	}
+	if filter.Limit < 0 || filter.Limit > model.MaxPageSize {
+		return model.TransactionFilter{}, fmt.Errorf("invalid limit, supported: 1 to %d", model.MaxPageSize)
+	}
+
+	if receiver.Cursor != "" {
+		cursor, err := model.DecodeCursor(receiver.Cursor)
+		if err != nil {
+			return model.TransactionFilter{}, err
+		}
+		filter.After = &cursor
+	}
+	return filter, nil
+}
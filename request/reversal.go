@@ -0,0 +1,10 @@
+package request
+
+import (
+	"main/model"
+)
+
+type ReversalRequest struct {
+	// Amount to refund, in the currency of the original transaction, omit to refund everything that is left
+	Amount model.Amount `json:"amount" example:"5.00" swaggertype:"number"`
+} //@name ReversalRequest
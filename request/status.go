@@ -0,0 +1,12 @@
+package request
+
+import (
+	"main/model"
+)
+
+type StatusRequest struct {
+	// New status: 'authorized', 'settled', 'failed' or 'cancelled'
+	Status model.Status `json:"status" binding:"required" example:"settled" swaggertype:"string"`
+	// Reason for the change
+	Reason string `json:"reason" example:"captured"`
+} //@name StatusRequest
@@ -1,14 +1,20 @@
 package request
 
+import (
+	"main/model"
+)
+
 type TransactionRequest struct {
 	// Sender account UUID
-	SenderAccountID string `json:"senderAccountID" example:"5d84ca00-c079-4577-9560-e1014086affe"`
-	// Recipient UUID
-	RecipientID string `json:"recipientID" example:"495d45e9-644c-40b8-94e8-103cad128331"`
-	// RecipientID account UUID
-	RecipientAccountID string `json:"recipientAccountID" example:"8cca0453-8e84-4f3b-aa40-7fc9cd162a34"`
+	SenderAccountID string `json:"senderAccountID" binding:"required,uuid" example:"5d84ca00-c079-4577-9560-e1014086affe"`
+	// Recipient customer UUID, must own the recipient account
+	RecipientID string `json:"recipientID" binding:"required,uuid" example:"495d45e9-644c-40b8-94e8-103cad128331"`
+	// Recipient account UUID
+	RecipientAccountID string `json:"recipientAccountID" binding:"required,uuid,nefield=SenderAccountID" example:"8cca0453-8e84-4f3b-aa40-7fc9cd162a34"`
 	// Transaction amount
-	Amount float64 `json:"amount" example:"17.24" minimum:"1"`
+	Amount model.Amount `json:"amount" binding:"required,minamount=1" example:"17.24" minimum:"1" swaggertype:"number"`
 	// Transaction type ID
-	Type int `json:"type" example:"1"`
+	Type int `json:"type" binding:"required,min=1" example:"1"`
+	// Convert the amount when the sender and recipient accounts use different currencies
+	Convert bool `json:"convert" example:"false"`
 } //@name TransactionRequest
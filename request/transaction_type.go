@@ -0,0 +1,9 @@
+package request
+
+type TransactionTypeRequest struct {
+	// TransactionType description
+	Type string `json:"type" binding:"required,max=255" example:"standing-order"`
+	// Transactions of the type are only authorized when created, and settled when captured. Only used when
+	// creating the type
+	RequiresCapture bool `json:"requiresCapture" example:"false"`
+} //@name TransactionTypeRequest
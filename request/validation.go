@@ -0,0 +1,88 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"main/model"
+	"main/response"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// Report fields by their JSON names.
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	_ = engine.RegisterValidation("minamount", func(fl validator.FieldLevel) bool {
+		minimum, err := model.ParseAmount(fl.Param())
+		return err == nil && fl.Field().Int() >= int64(minimum)
+	})
+}
+
+// FieldErrors returns the field errors of a failed JSON binding, or nil if err isn't tied to fields.
+func FieldErrors(err error) []response.FieldError {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fields := make([]response.FieldError, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			fields[i] = fieldErrorOf(fieldError)
+		}
+		return fields
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return []response.FieldError{{Field: typeError.Field, Code: response.FieldInvalidType,
+			Message: "must be of type " + typeError.Type.String()}}
+	}
+
+	// Amount is the only field type with its own parsing.
+	if errors.Is(err, model.ErrInvalidAmount) {
+		return []response.FieldError{{Field: "amount", Code: response.FieldInvalid,
+			Message: "must be a number with at most two decimal places"}}
+	}
+	return nil
+}
+
+func fieldErrorOf(fieldError validator.FieldError) response.FieldError {
+	result := response.FieldError{Field: fieldError.Field()}
+
+	switch fieldError.Tag() {
+	case "required":
+		result.Code, result.Message = response.FieldRequired, "is required"
+	case "uuid":
+		result.Code, result.Message = response.FieldInvalidUUID, "must be a valid UUID"
+	case "min", "minamount":
+		result.Code, result.Message = response.FieldTooSmall, "must be at least "+fieldError.Param()
+	case "max":
+		result.Code, result.Message = response.FieldTooLong, "must be at most "+fieldError.Param()+" characters"
+	case "nefield":
+		result.Code, result.Message = response.FieldMustDiffer, "must differ from "+lowerFirst(fieldError.Param())
+	default:
+		result.Code, result.Message = response.FieldInvalid, "is invalid"
+	}
+	return result
+}
+
+// lowerFirst turns a struct field name into its JSON name.
+func lowerFirst(name string) string {
+	runes := []rune(name)
+	if len(runes) > 0 {
+		runes[0] = unicode.ToLower(runes[0])
+	}
+	return string(runes)
+}
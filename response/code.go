@@ -0,0 +1,66 @@
+package response
+
+// Error codes returned in ErrorResponse.Code. Clients may rely on them, so existing values must not change.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeUnprocessable        = "unprocessable"
+	CodeInternal             = "internal_error"
+	CodeTransactionNotFound  = "transaction_not_found"
+	CodeTypeNotFound         = "type_not_found"
+	CodeTypeExists           = "type_exists"
+	CodeTypeInactive         = "type_inactive"
+	CodeInsufficientFunds    = "insufficient_funds"
+	CodeRefundExceeded       = "refund_exceeded"
+	CodeReversalOfReversal   = "reversal_of_reversal"
+	CodeNotSettled           = "not_settled"
+	CodeIllegalTransition    = "illegal_transition"
+	CodeNotFinal             = "not_final"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeInvalidCursor        = "invalid_cursor"
+	CodeRateNotFound         = "rate_not_found"
+	CodeInvalidRecipient     = "invalid_recipient"
+	CodeAccountNotFound      = "account_not_found"
+	CodeAccountUnavailable   = "account_service_unavailable"
+	CodeUpstream             = "upstream_error"
+	CodeServiceUnavailable   = "service_unavailable"
+)
+
+// Field error codes returned in FieldError.Code.
+const (
+	FieldRequired    = "required"
+	FieldInvalid     = "invalid"
+	FieldInvalidType = "invalid_type"
+	FieldInvalidUUID = "invalid_uuid"
+	FieldTooSmall    = "too_small"
+	FieldTooLong     = "too_long"
+	FieldMustDiffer  = "must_differ"
+)
+
+// StatusCode returns the generic code for an HTTP status, used when no specific code applies.
+func StatusCode(status int) string {
+	switch status {
+	case 400:
+		return CodeInvalidRequest
+	case 401:
+		return CodeUnauthorized
+	case 403:
+		return CodeForbidden
+	case 404:
+		return CodeNotFound
+	case 409:
+		return CodeConflict
+	case 422:
+		return CodeUnprocessable
+	case 502:
+		return CodeUpstream
+	case 503:
+		return CodeServiceUnavailable
+	default:
+		return CodeInternal
+	}
+}
@@ -0,0 +1,21 @@
+package response
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStatusCode(t *testing.T) {
+	tests := map[int]string{
+		http.StatusBadRequest:          CodeInvalidRequest,
+		http.StatusConflict:            CodeConflict,
+		http.StatusInternalServerError: CodeInternal,
+		http.StatusBadGateway:          CodeUpstream,
+		http.StatusServiceUnavailable:  CodeServiceUnavailable,
+	}
+	for status, want := range tests {
+		if got := StatusCode(status); got != want {
+			t.Errorf("StatusCode(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
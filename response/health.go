@@ -0,0 +1,12 @@
+package response
+
+type HealthResponse struct {
+	// 'ok', 'degraded' when logs can't be published, or 'unavailable' when the database can't be reached.
+	Status string `json:"status" example:"ok"`
+	// Database state: 'up' or 'down'
+	Database string `json:"database" example:"up"`
+	// Log broker connection state: 'connected' or 'disconnected'
+	Messaging string `json:"messaging" example:"connected"`
+	// Log lines dropped because the buffer was full
+	DroppedLogs uint64 `json:"droppedLogs" example:"0"`
+} //@name HealthResponse
@@ -0,0 +1,60 @@
+package response
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is the RFC 7807 variant of ErrorResponse, sent to clients that accept application/problem+json.
+type Problem struct {
+	// Problem type URI, about:blank since codes identify the problem.
+	Type string `json:"type" example:"about:blank"`
+	// HTTP status text.
+	Title string `json:"title" example:"Bad Request"`
+	// HTTP status code.
+	Status int `json:"status" example:"400"`
+	// Error description.
+	Detail string `json:"detail" example:"invalid account id"`
+	// Request path.
+	Instance string `json:"instance" example:"/api/v1/balance/abc"`
+	// Machine-readable error code, stable across releases.
+	Code string `json:"code" example:"invalid_request"`
+	// Field errors, set when the request body fails validation.
+	Fields []FieldError `json:"fields,omitempty"`
+	// Correlation ID of the request, also sent in the Correlation header.
+	Correlation string `json:"correlation,omitempty" example:"0b3c4e4e-8a51-4c7f-8f59-6a4c5bb0d3a2"`
+} //@name Problem
+
+// Send writes body with status, as application/problem+json if the client accepts it.
+func Send(ctx *gin.Context, status int, body ErrorResponse) {
+	if body.Code == "" {
+		body.Code = StatusCode(status)
+	}
+	// Set by the correlation middleware.
+	body.Correlation = ctx.Writer.Header().Get("Correlation")
+
+	if !strings.Contains(ctx.GetHeader("Accept"), ProblemContentType) {
+		ctx.JSON(status, body)
+		return
+	}
+
+	data, err := json.Marshal(Problem{
+		Type:        "about:blank",
+		Title:       http.StatusText(status),
+		Status:      status,
+		Detail:      body.Error,
+		Instance:    ctx.Request.URL.Path,
+		Code:        body.Code,
+		Fields:      body.Fields,
+		Correlation: body.Correlation,
+	})
+	if err != nil {
+		ctx.JSON(status, body)
+		return
+	}
+	ctx.Data(status, ProblemContentType, data)
+}
@@ -3,4 +3,19 @@ package response
 type ErrorResponse struct {
 	// Error description.
 	Error string `json:"error" example:"invalid account id"`
+	// Machine-readable error code, stable across releases.
+	Code string `json:"code" example:"invalid_request"`
+	// Field errors, set when the request body fails validation.
+	Fields []FieldError `json:"fields,omitempty"`
+	// Correlation ID of the request, also sent in the Correlation header.
+	Correlation string `json:"correlation,omitempty" example:"0b3c4e4e-8a51-4c7f-8f59-6a4c5bb0d3a2"`
 } //@name ErrorResponse
+
+type FieldError struct {
+	// JSON name of the field.
+	Field string `json:"field" example:"senderAccountID"`
+	// Machine-readable error code.
+	Code string `json:"code" example:"invalid_uuid"`
+	// Error description.
+	Message string `json:"message" example:"must be a valid UUID"`
+} //@name FieldError
@@ -0,0 +1,12 @@
+package response
+
+import (
+	"main/model"
+)
+
+type TransactionPage struct {
+	// Transactions on this page, newest first
+	Transactions []model.Transaction `json:"transactions"`
+	// Cursor of the next page, empty on the last page
+	Next string `json:"next,omitempty" example:"MjAyMi0xMi0yMSAwODo0NToxMnw0YTVlZDJlMA"`
+} //@name TransactionPage
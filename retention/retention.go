@@ -0,0 +1,64 @@
+package retention
+
+import (
+	"context"
+	"log"
+	"main/db"
+	"time"
+)
+
+// Job purges the details of soft-deleted transactions once they are older than the legal hold period,
+// delivered outbox messages once they are older than OutboxHold, and expired idempotency keys.
+type Job struct {
+	DB         *db.TransactionDB
+	Hold       time.Duration
+	OutboxHold time.Duration
+	Interval   time.Duration
+}
+
+func (receiver Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(receiver.Interval)
+	defer ticker.Stop()
+
+	for {
+		receiver.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (receiver Job) purge(ctx context.Context) {
+	purged, err := receiver.DB.Purge(ctx, time.Now().Add(-receiver.Hold))
+	if err != nil {
+		log.Printf("retention purge error: %v", err)
+		return
+	}
+
+	if purged > 0 {
+		log.Printf("retention purged %d transactions", purged)
+	}
+
+	pruned, err := receiver.DB.PruneOutbox(ctx, time.Now().Add(-receiver.OutboxHold))
+	if err != nil {
+		log.Printf("retention outbox error: %v", err)
+		return
+	}
+
+	if pruned > 0 {
+		log.Printf("retention pruned %d outbox messages", pruned)
+	}
+
+	expired, err := receiver.DB.PruneIdempotencyKeys(ctx, time.Now())
+	if err != nil {
+		log.Printf("retention idempotency key error: %v", err)
+		return
+	}
+
+	if expired > 0 {
+		log.Printf("retention pruned %d idempotency keys", expired)
+	}
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Init installs the global tracer provider and the W3C trace-context and baggage propagators. With
+// ExporterOTLP spans are sent over OTLP/HTTP, to the endpoint set by the standard OTEL_EXPORTER_OTLP_*
+// env variables. With ExporterStdout they are printed, to verify tracing locally. With ExporterNone, or
+// an empty exporter, spans are only used to propagate the trace context.
+// The returned function flushes the remaining spans and must be called before exit.
+func Init(ctx context.Context, exporter, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{},
+		propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %q, supported: %q, %q, %q", exporter, ExporterNone,
+			ExporterOTLP, ExporterStdout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the service name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Headers carries the trace context in the headers of an AMQP message.
+type Headers amqp.Table
+
+func (receiver Headers) Get(key string) string {
+	value, _ := receiver[key].(string)
+	return value
+}
+
+func (receiver Headers) Set(key, value string) {
+	receiver[key] = value
+}
+
+func (receiver Headers) Keys() []string {
+	keys := make([]string, 0, len(receiver))
+	for key := range receiver {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Inject returns AMQP headers holding the trace context of ctx.
+func Inject(ctx context.Context) amqp.Table {
+	headers := Headers{}
+	otel.GetTextMapPropagator().Inject(ctx, headers)
+	return amqp.Table(headers)
+}
+
+// End records err on the span, if it isn't nil, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
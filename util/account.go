@@ -0,0 +1,247 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log"
+	"main/model"
+	"main/tracing"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var tracer = otel.Tracer("main/util")
+
+var (
+	ErrAccountNotFound    = errors.New("account not found")
+	ErrAccountUnavailable = errors.New("account service unavailable")
+)
+
+// AccountError is returned by AccountClient when the account API doesn't respond with 2xx.
+type AccountError struct {
+	StatusCode int
+	Body       string
+}
+
+func (receiver *AccountError) Error() string {
+	if receiver.StatusCode == http.StatusNotFound {
+		return ErrAccountNotFound.Error()
+	}
+	if receiver.Body == "" {
+		return "error: account API responded with " + strconv.Itoa(receiver.StatusCode)
+	}
+	return "error: " + receiver.Body
+}
+
+// Is makes a 404 from the account API match ErrAccountNotFound.
+func (receiver *AccountError) Is(target error) bool {
+	return target == ErrAccountNotFound && receiver.StatusCode == http.StatusNotFound
+}
+
+const (
+	accountRetries    = 2
+	accountRetryDelay = 100 * time.Millisecond
+	breakerThreshold  = 5
+	breakerCooldown   = 30 * time.Second
+)
+
+// AccountRetryAfter is how long callers should wait after ErrAccountUnavailable before trying again.
+const AccountRetryAfter = breakerCooldown
+
+// AccountClient fetches accounts from the account API. Failed requests are retried with jittered
+// backoff, and after breakerThreshold consecutive failures requests fail fast with
+// ErrAccountUnavailable for breakerCooldown.
+type AccountClient struct {
+	BaseURL string
+	// Token used for calls made by the service itself, such as balance updates
+	ServiceToken string
+
+	client *http.Client
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewAccountClient(baseURL, serviceToken string, timeout time.Duration) *AccountClient {
+	return &AccountClient{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		ServiceToken: serviceToken,
+		// The transport starts a client span for every request and sends the trace context along.
+		client: &http.Client{Timeout: timeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+// Get returns the account, authorized with the caller's token.
+func (receiver *AccountClient) Get(accountID string, context *gin.Context) (_ model.Account, err error) {
+	fetchedAt := time.Now()
+
+	// The span covers all attempts, each of which has its own HTTP span.
+	ctx, span := tracer.Start(context.Request.Context(), "AccountClient.Get",
+		trace.WithAttributes(attribute.String("account.id", accountID)))
+	defer func() { tracing.End(span, err) }()
+
+	if !receiver.allow() {
+		return model.Account{}, ErrAccountUnavailable
+	}
+
+	data, err := receiver.get(ctx, "/api/v1/account/"+accountID, Token(context), CorrelationID(context))
+	receiver.record(ctx, err)
+	if err != nil {
+		return model.Account{}, err
+	}
+
+	var acc model.Account
+	if err := json.Unmarshal(data, &acc); err != nil {
+		return model.Account{}, err
+	}
+	acc.FetchedAt = fetchedAt
+	return acc, nil
+}
+
+// get performs the GET, retrying network errors and responses that are worth retrying.
+func (receiver *AccountClient) get(ctx context.Context, path, token, correlation string) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt <= accountRetries; attempt++ {
+		if attempt > 0 {
+			// Full jitter over an exponential backoff.
+			delay := time.Duration(rand.Int63n(int64(accountRetryDelay << attempt)))
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, receiver.BaseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("Authorization", "Bearer "+token)
+		req.Header.Add(CorrelationHeader, correlation)
+
+		var data []byte
+		data, err = receiver.do(req)
+		if err == nil || !retryable(ctx, err) {
+			return data, err
+		}
+	}
+	return nil, err
+}
+
+// UpdateBalance sends the balance update to the account service. It isn't retried here, since the
+// outbox relay retries it. The update ID is sent as Idempotency-Key, so a repeated update is applied once.
+func (receiver *AccountClient) UpdateBalance(ctx context.Context, update model.BalanceUpdate,
+	correlation string) error {
+	if !receiver.allow() {
+		return ErrAccountUnavailable
+	}
+
+	body, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		receiver.BaseURL+"/api/v1/account/"+update.AccountID+"/balance", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", "Bearer "+receiver.ServiceToken)
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Idempotency-Key", update.ID)
+	req.Header.Add(CorrelationHeader, correlation)
+
+	_, err = receiver.do(req)
+	receiver.record(ctx, err)
+	return err
+}
+
+func (receiver *AccountClient) do(req *http.Request) ([]byte, error) {
+	res, err := receiver.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(body io.ReadCloser) {
+		if err := body.Close(); err != nil {
+			log.Printf("Close error: %s\n", err)
+		}
+	}(res.Body)
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &AccountError{StatusCode: res.StatusCode, Body: string(data)}
+	}
+	return data, nil
+}
+
+// retryable reports whether err is a network error, including a timed out attempt, or a response the
+// account API may not repeat. Nothing is retried once the caller's ctx is done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var accErr *AccountError
+	if errors.As(err, &accErr) {
+		return accErr.StatusCode == http.StatusTooManyRequests || accErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// allow reports whether a request may be sent. Once the cooldown has passed, one probe request is let
+// through, and its result closes or reopens the breaker.
+func (receiver *AccountClient) allow() bool {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	if receiver.failures < breakerThreshold {
+		return true
+	}
+	if receiver.probing || time.Now().Before(receiver.openUntil) {
+		return false
+	}
+	receiver.probing = true
+	return true
+}
+
+// record counts err as a failure if it is retryable, timed out attempts included, and resets the
+// failures otherwise.
+func (receiver *AccountClient) record(ctx context.Context, err error) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	receiver.probing = false
+	if err != nil && ctx.Err() != nil {
+		// The caller went away or ran out of time, which says nothing about the account API.
+		return
+	}
+	if err == nil || !retryable(ctx, err) {
+		receiver.failures = 0
+		return
+	}
+
+	receiver.failures++
+	if receiver.failures >= breakerThreshold {
+		receiver.openUntil = time.Now().Add(breakerCooldown)
+		log.Printf("account API circuit open: %v", err)
+	}
+}
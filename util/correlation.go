@@ -0,0 +1,50 @@
+package util
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	CorrelationHeader = "Correlation"
+
+	correlationKey       = "Correlation"
+	maxCorrelationLength = 128
+)
+
+// Correlate gives the request its correlation ID, taken from the Correlation header if the caller sent
+// a usable one, and echoes it in the response. It must run before every other middleware, except
+// tracing, which adds the ID to the request's span.
+func Correlate(context *gin.Context) {
+	id := context.GetHeader(CorrelationHeader)
+	if !isValidCorrelation(id) {
+		id = uuid.NewString()
+	}
+
+	context.Set(correlationKey, id)
+	context.Header(CorrelationHeader, id)
+	trace.SpanFromContext(context.Request.Context()).SetAttributes(attribute.String("correlation", id))
+	context.Next()
+}
+
+// CorrelationID returns the correlation ID of the request.
+func CorrelationID(context *gin.Context) string {
+	return context.GetString(correlationKey)
+}
+
+// isValidCorrelation accepts IDs that are safe to forward in headers and log lines.
+func isValidCorrelation(id string) bool {
+	if id == "" || len(id) > maxCorrelationLength {
+		return false
+	}
+
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' ||
+			r == '.' || r == ':') {
+			return false
+		}
+	}
+	return true
+}
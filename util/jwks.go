@@ -0,0 +1,227 @@
+package util
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownKey   = errors.New("unknown signing key")
+	ErrKeyAlgorithm = errors.New("signing key is for another algorithm")
+)
+
+// minRefreshInterval limits refreshes triggered by tokens with an unknown kid.
+const minRefreshInterval = time.Minute
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// signingKey is a public key together with the algorithm its JWK is restricted to, if any.
+type signingKey struct {
+	key interface{}
+	alg string
+}
+
+// JWKS holds the public keys of a JSON Web Key Set, loaded from a file or an HTTP URL, by kid.
+type JWKS struct {
+	Source string
+
+	client *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]signingKey
+	refreshedAt time.Time
+}
+
+func NewJWKS(source string) (*JWKS, error) {
+	jwks := &JWKS{
+		Source: source,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+
+	if err := jwks.Refresh(); err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+// Key returns the public key with the given kid to verify a token signed with alg. Keys whose JWK names
+// another algorithm are refused. An unknown kid triggers a refresh, so rotated keys are picked up before
+// the next scheduled refresh.
+func (receiver *JWKS) Key(kid, alg string) (interface{}, error) {
+	receiver.mu.RLock()
+	key, ok := receiver.keys[kid]
+	refreshedAt := receiver.refreshedAt
+	receiver.mu.RUnlock()
+
+	if !ok {
+		if time.Since(refreshedAt) < minRefreshInterval {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+		}
+
+		if err := receiver.Refresh(); err != nil {
+			return nil, err
+		}
+
+		receiver.mu.RLock()
+		key, ok = receiver.keys[kid]
+		receiver.mu.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+		}
+	}
+
+	if key.alg != "" && key.alg != alg {
+		return nil, fmt.Errorf("%w: %q is for %s, not %s", ErrKeyAlgorithm, kid, key.alg, alg)
+	}
+	return key.key, nil
+}
+
+func (receiver *JWKS) Refresh() error {
+	data, err := receiver.read()
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+
+	keys := map[string]signingKey{}
+	for _, value := range set.Keys {
+		if value.Use != "" && value.Use != "sig" {
+			continue
+		}
+
+		key, err := value.publicKey()
+		if err != nil {
+			log.Printf("skipping jwk %q: %v", value.Kid, err)
+			continue
+		}
+		keys[value.Kid] = signingKey{key: key, alg: value.Alg}
+	}
+
+	receiver.mu.Lock()
+	receiver.keys = keys
+	receiver.refreshedAt = time.Now()
+	receiver.mu.Unlock()
+	return nil
+}
+
+// Run refreshes the keys every interval until ctx is done.
+func (receiver *JWKS) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := receiver.Refresh(); err != nil {
+				log.Printf("jwks refresh error: %v", err)
+			}
+		}
+	}
+}
+
+func (receiver *JWKS) read() ([]byte, error) {
+	if !strings.HasPrefix(receiver.Source, "http://") && !strings.HasPrefix(receiver.Source, "https://") {
+		return os.ReadFile(receiver.Source)
+	}
+
+	res, err := receiver.client.Get(receiver.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer func(body io.ReadCloser) {
+		if err := body.Close(); err != nil {
+			log.Printf("Close error: %s\n", err)
+		}
+	}(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks responded with %d", res.StatusCode)
+	}
+	return io.ReadAll(res.Body)
+}
+
+func (receiver jwk) publicKey() (interface{}, error) {
+	switch receiver.Kty {
+	case "RSA":
+		n, err := decodeBigInt(receiver.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(receiver.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch receiver.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %q", receiver.Crv)
+		}
+
+		x, err := decodeBigInt(receiver.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(receiver.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %q", receiver.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
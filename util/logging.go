@@ -1,63 +1,131 @@
 package util
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
-	"github.com/google/uuid"
-	"os"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func logging(level string, context *gin.Context) string {
-	var sb strings.Builder
-
-	sb.WriteString("time=" + time.Now().Format("2006-01-02 15-04-05"))
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
 
-	context.Set("Correlation", uuid.NewString())
-	sb.WriteString(" id=" + context.MustGet("Correlation").(string))
+	startKey = "RequestStart"
+)
 
-	sb.WriteString(" level=" + level)
-	sb.WriteString(" path=" + context.Request.RequestURI)
+var logFormat = LogFormatJSON
 
-	correlation := context.GetHeader("Correlation")
-	if correlation == "" {
-		correlation = "nil"
+// UseLogFormat selects how log lines are formatted, LogFormatJSON or LogFormatText.
+func UseLogFormat(format string) error {
+	if format != LogFormatJSON && format != LogFormatText {
+		return fmt.Errorf("invalid log format %q, supported: %q, %q", format, LogFormatJSON, LogFormatText)
 	}
-	sb.WriteString(" correlation=" + correlation)
-	sb.WriteString(" ip=" + context.ClientIP())
+	logFormat = format
+	return nil
+}
+
+// LogEvent describes the outcome of a request.
+type LogEvent struct {
+	Time  time.Time `json:"time"`
+	Level string    `json:"level"`
+	// Error message, for error events
+	Message string `json:"msg,omitempty"`
+	// Correlation ID of the request
+	Correlation string `json:"correlation"`
+	// Trace ID of the request, when it is traced
+	TraceID string `json:"traceId,omitempty"`
+	Method  string `json:"method"`
+	// Route template, such as /api/v1/balance/:accountID
+	Route     string  `json:"route"`
+	Path      string  `json:"path"`
+	Status    int     `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	// Response body size
+	Bytes     int    `json:"bytes"`
+	IP        string `json:"ip"`
+	Principal string `json:"principal,omitempty"`
+}
 
-	var token string
-	values := strings.Split(context.GetHeader("Authorization"), "Bearer ")
+// Begin marks the start of the request. It must run before the handler.
+func Begin(context *gin.Context) {
+	context.Set(startKey, time.Now())
+}
 
-	if len(values) == 2 {
-		token = values[1]
+func newLogEvent(level, message string, context *gin.Context) LogEvent {
+	event := LogEvent{
+		Time:        time.Now(),
+		Level:       level,
+		Message:     message,
+		Correlation: CorrelationID(context),
+		Method:      context.Request.Method,
+		Route:       context.FullPath(),
+		Path:        context.Request.RequestURI,
+		Status:      context.Writer.Status(),
+		Bytes:       context.Writer.Size(),
+		IP:          context.ClientIP(),
+	}
+
+	if span := trace.SpanContextFromContext(context.Request.Context()); span.HasTraceID() {
+		event.TraceID = span.TraceID().String()
+	}
+	if start, ok := context.Value(startKey).(time.Time); ok {
+		event.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	}
+	if event.Bytes < 0 {
+		event.Bytes = 0
+	}
 
-		to, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+	// Only ValidateToken verifies tokens, requests it rejected or never saw are logged without a principal.
+	event.Principal = Subject(context)
+	return event
+}
 
-		if err == nil && to.Valid {
-			if claims, ok := to.Claims.(jwt.MapClaims); ok {
-				token = claims["sub"].(string)
-			}
+func (receiver LogEvent) String() string {
+	if logFormat == LogFormatJSON {
+		data, err := json.Marshal(receiver)
+		if err == nil {
+			return string(data)
 		}
-	} else {
-		token = "nil"
 	}
-	sb.WriteString(" auth=" + token)
 
+	var sb strings.Builder
+
+	sb.WriteString("time=" + receiver.Time.Format(time.RFC3339Nano))
+	sb.WriteString(" correlation=" + orNil(receiver.Correlation))
+	sb.WriteString(" trace=" + orNil(receiver.TraceID))
+	sb.WriteString(" level=" + receiver.Level)
+	sb.WriteString(" method=" + receiver.Method)
+	sb.WriteString(" route=" + orNil(receiver.Route))
+	sb.WriteString(" path=" + receiver.Path)
+	sb.WriteString(" status=" + strconv.Itoa(receiver.Status))
+	sb.WriteString(" latency_ms=" + strconv.FormatFloat(receiver.LatencyMs, 'f', 3, 64))
+	sb.WriteString(" bytes=" + strconv.Itoa(receiver.Bytes))
+	sb.WriteString(" ip=" + receiver.IP)
+	sb.WriteString(" auth=" + orNil(receiver.Principal))
+
+	if receiver.Message != "" {
+		sb.WriteString(" msg=" + receiver.Message)
+	}
 	return sb.String()
 }
 
+func orNil(value string) string {
+	if value == "" {
+		return "nil"
+	}
+	return value
+}
+
+// Info returns the log line for a completed request.
 func Info(context *gin.Context) string {
-	return logging("info", context)
+	return newLogEvent("info", "", context).String()
 }
 
+// Error returns the log line for an error recorded while handling the request.
 func Error(err string, context *gin.Context) string {
-	return logging("error", context) + " msg=" + err
+	return newLogEvent("error", err, context).String()
 }
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const maxOwnershipEntries = 10000
+
+type ownershipKey struct {
+	subject   string
+	accountID string
+}
+
+type ownershipEntry struct {
+	owned   bool
+	expires time.Time
+}
+
+// Ownership resolves whether the token subject owns an account, through the account API. Answers
+// are cached per subject and account for TTL.
+type Ownership struct {
+	Accounts *AccountClient
+	TTL      time.Duration
+
+	mu    sync.Mutex
+	cache map[ownershipKey]ownershipEntry
+}
+
+func NewOwnership(accounts *AccountClient, ttl time.Duration) *Ownership {
+	return &Ownership{
+		Accounts: accounts,
+		TTL:      ttl,
+		cache:    map[ownershipKey]ownershipEntry{},
+	}
+}
+
+// Owns reports whether the caller owns the account. An account the account API doesn't return to
+// the caller isn't owned by them.
+func (receiver *Ownership) Owns(accountID string, context *gin.Context) (bool, error) {
+	subject := Subject(context)
+	if subject == "" {
+		return false, nil
+	}
+
+	key := ownershipKey{subject: subject, accountID: accountID}
+	if owned, ok := receiver.get(key); ok {
+		return owned, nil
+	}
+
+	acc, err := receiver.Accounts.Get(accountID, context)
+
+	var accErr *AccountError
+	if errors.As(err, &accErr) && (accErr.StatusCode == http.StatusNotFound ||
+		accErr.StatusCode == http.StatusForbidden || accErr.StatusCode == http.StatusUnauthorized) {
+		receiver.set(key, false)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	owned := acc.CustomerID == subject
+	receiver.set(key, owned)
+	return owned, nil
+}
+
+// Remember caches ownership of an account that was already fetched from the account API.
+func (receiver *Ownership) Remember(accountID, customerID string, context *gin.Context) bool {
+	subject := Subject(context)
+	owned := subject != "" && customerID == subject
+	if subject != "" {
+		receiver.set(ownershipKey{subject: subject, accountID: accountID}, owned)
+	}
+	return owned
+}
+
+func (receiver *Ownership) get(key ownershipKey) (bool, bool) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	entry, ok := receiver.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return false, false
+	}
+	return entry.owned, true
+}
+
+func (receiver *Ownership) set(key ownershipKey, owned bool) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	now := time.Now()
+	if len(receiver.cache) >= maxOwnershipEntries {
+		for k, entry := range receiver.cache {
+			if now.After(entry.expires) {
+				delete(receiver.cache, k)
+			}
+		}
+	}
+
+	if len(receiver.cache) < maxOwnershipEntries {
+		receiver.cache[key] = ownershipEntry{owned: owned, expires: now.Add(receiver.TTL)}
+	}
+}
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"strings"
+	"time"
+)
+
+const principalKey = "principal"
+
+var (
+	ErrMissingToken = errors.New("unauthorized")
+	ErrMalformed    = errors.New("token is not set properly")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Principal is the verified caller of a request.
+type Principal struct {
+	// Token subject, the customer UUID
+	Subject string
+	// Scopes granted by the token
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// Raw token, forwarded to the account API
+	Token string
+}
+
+// ParseToken verifies the Authorization header value and returns its principal. Claims of the wrong
+// type are reported as errors.
+func ParseToken(header string) (Principal, error) {
+	if header == "" {
+		return Principal{}, ErrMissingToken
+	}
+
+	token := strings.TrimPrefix(header, "Bearer ")
+	ok := token != header
+	if !ok || token == "" {
+		return Principal{}, ErrMalformed
+	}
+
+	to, err := jwt.Parse(token, keyFunc)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	claims, ok := to.Claims.(jwt.MapClaims)
+	if !to.Valid || !ok {
+		return Principal{}, ErrInvalidToken
+	}
+
+	if err := verifyIssuerAudience(claims); err != nil {
+		return Principal{}, err
+	}
+
+	subject, ok := claims["sub"].(string)
+	if !ok || subject == "" {
+		return Principal{}, errors.New("invalid id")
+	}
+
+	iat, iatOk := timeClaim(claims, "iat")
+	exp, expOk := timeClaim(claims, "exp")
+	if !iatOk || !expOk {
+		return Principal{}, errors.New("iat or exp not set")
+	}
+
+	if iat.After(time.Now()) {
+		return Principal{}, errors.New("iat can't be in the future")
+	}
+
+	if exp.Before(time.Now()) {
+		return Principal{}, errors.New("expired token")
+	}
+
+	return Principal{
+		Subject:   subject,
+		Scopes:    Scopes(claims),
+		IssuedAt:  iat,
+		ExpiresAt: exp,
+		Token:     token,
+	}, nil
+}
+
+func timeClaim(claims jwt.MapClaims, name string) (time.Time, bool) {
+	switch value := claims[name].(type) {
+	case float64:
+		return time.Unix(int64(value), 0), true
+	case json.Number:
+		seconds, err := value.Int64()
+		return time.Unix(seconds, 0), err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+// GetPrincipal returns the principal ValidateToken placed on the context.
+func GetPrincipal(context *gin.Context) (Principal, bool) {
+	value, ok := context.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	principal, ok := value.(Principal)
+	return principal, ok
+}
+
+// Subject returns the subject of the verified caller, or an empty string.
+func Subject(context *gin.Context) string {
+	principal, _ := GetPrincipal(context)
+	return principal.Subject
+}
+
+// Token returns the raw token of the verified caller, or an empty string.
+func Token(context *gin.Context) string {
+	principal, _ := GetPrincipal(context)
+	return principal.Token
+}
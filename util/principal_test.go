@@ -0,0 +1,98 @@
+package util
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt"
+	"testing"
+	"time"
+)
+
+const fuzzSecret = "fuzz-secret"
+
+// signHS256 signs the claims as they are, so the fuzzer can produce claims of any type.
+func signHS256(tb testing.TB, claims string) string {
+	tb.Helper()
+
+	signingString := jwt.EncodeSegment([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		jwt.EncodeSegment([]byte(claims))
+	signature, err := jwt.SigningMethodHS256.Sign(signingString, []byte(fuzzSecret))
+	if err != nil {
+		tb.Fatalf("Sign() error: %v", err)
+	}
+	return signingString + "." + signature
+}
+
+func checkPrincipal(t *testing.T, principal Principal, err error) {
+	t.Helper()
+
+	if err != nil {
+		return
+	}
+	if principal.Subject == "" {
+		t.Errorf("accepted a token without a subject")
+	}
+	if principal.ExpiresAt.Before(time.Now()) {
+		t.Errorf("accepted a token that expired at %v", principal.ExpiresAt)
+	}
+	if principal.IssuedAt.After(time.Now()) {
+		t.Errorf("accepted a token issued at %v", principal.IssuedAt)
+	}
+}
+
+func FuzzParseToken(f *testing.F) {
+	f.Setenv("JWT_SECRET", fuzzSecret)
+	f.Setenv("JWT_ISSUER", "")
+	f.Setenv("JWT_AUDIENCE", "")
+
+	for _, seed := range []string{
+		"",
+		"Bearer",
+		"Bearer ",
+		"Bearer a.b.c",
+		"Basic dXNlcjpwYXNz",
+		"Bearer " + signHS256(f, `{"sub":"a","iat":1,"exp":9999999999}`),
+		"Bearer " + signHS256(f, `{"sub":1,"iat":"1","exp":[]}`),
+		"Bearer eyJhbGciOiJub25lIn0.eyJzdWIiOiJhIn0.",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, header string) {
+		principal, err := ParseToken(header)
+		checkPrincipal(t, principal, err)
+	})
+}
+
+func FuzzParseTokenClaims(f *testing.F) {
+	f.Setenv("JWT_SECRET", fuzzSecret)
+	f.Setenv("JWT_ISSUER", "")
+	f.Setenv("JWT_AUDIENCE", "")
+
+	now := time.Now().Unix()
+	for _, claims := range []map[string]any{
+		{"sub": "495d45e9-644c-40b8-94e8-103cad128331", "iat": now - 60, "exp": now + 3600,
+			"scope": "transactions:read"},
+		{"sub": "a", "iat": now - 60, "exp": now + 3600, "scopes": []any{"transactions:write", 1, nil}},
+		{"sub": 42, "iat": now, "exp": now + 3600},
+		{"sub": "a", "iat": "yesterday", "exp": now + 3600},
+		{"sub": "a", "iat": now, "exp": nil},
+		{"sub": "a", "iat": now, "exp": now - 1},
+		{"sub": "a", "iat": now + 3600, "exp": now + 7200},
+		{"sub": "", "iat": now, "exp": now + 3600, "scope": []any{}},
+		{"sub": "a", "exp": now + 3600, "nbf": "soon"},
+	} {
+		data, err := json.Marshal(claims)
+		if err != nil {
+			f.Fatalf("json.Marshal() error: %v", err)
+		}
+		f.Add(string(data))
+	}
+	f.Add(`[]`)
+	f.Add(`null`)
+	f.Add(`{"sub":{"id":"a"}}`)
+
+	f.Fuzz(func(t *testing.T, claims string) {
+		principal, err := ParseToken("Bearer " + signHS256(t, claims))
+		checkPrincipal(t, principal, err)
+	})
+}
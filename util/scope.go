@@ -0,0 +1,75 @@
+package util
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"main/response"
+	"net/http"
+	"strings"
+)
+
+const (
+	ScopeRead  = "transactions:read"
+	ScopeWrite = "transactions:write"
+	// ScopeService is granted to other services, like the payment processor. It grants read and write
+	// on every account, but not the admin operations.
+	ScopeService = "transactions:service"
+	ScopeAdmin   = "transactions:admin"
+)
+
+// Scopes returns the scopes granted by the token, from the space-separated "scope" claim or the
+// "scopes" array claim.
+func Scopes(claims jwt.MapClaims) []string {
+	var scopes []string
+
+	if scope, ok := claims["scope"].(string); ok {
+		scopes = append(scopes, strings.Fields(scope)...)
+	}
+
+	if values, ok := claims["scopes"].([]interface{}); ok {
+		for _, value := range values {
+			if scope, ok := value.(string); ok {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// HasScope reports whether the token grants the scope. The admin scope grants every other scope, the
+// service scope grants read and write.
+func HasScope(context *gin.Context, scope string) bool {
+	principal, _ := GetPrincipal(context)
+
+	for _, value := range principal.Scopes {
+		if value == scope || value == ScopeAdmin {
+			return true
+		}
+		if value == ScopeService && (scope == ScopeRead || scope == ScopeWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+func IsAdmin(context *gin.Context) bool {
+	return HasScope(context, ScopeAdmin)
+}
+
+// IsPrivileged reports whether the caller is an admin or a service, which act on every account.
+func IsPrivileged(context *gin.Context) bool {
+	return HasScope(context, ScopeService)
+}
+
+// RequireScope aborts with 403 unless the token grants the scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if !HasScope(context, scope) {
+			response.Send(context, http.StatusForbidden, response.ErrorResponse{Error: "forbidden",
+				Code: response.CodeForbidden})
+			context.Abort()
+			return
+		}
+		context.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"os"
+)
+
+var keySet *JWKS
+
+// UseJWKS enables verification of RS256 and ES256 tokens with keys from the set.
+func UseJWKS(jwks *JWKS) {
+	keySet = jwks
+}
+
+// keyAlgorithms are the asymmetric algorithms accepted with JWKS keys. Other RSA and ECDSA variants are
+// rejected, so a token can't pick an algorithm the key wasn't issued for.
+var keyAlgorithms = map[string]bool{"RS256": true, "ES256": true}
+
+// keyFunc returns the key that verifies the token: JWT_SECRET for HMAC tokens, and the JWKS key
+// matching the kid header for RS256 and ES256 tokens.
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return nil, errors.New("HMAC tokens are not accepted")
+		}
+		return []byte(secret), nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		if keySet == nil || !keyAlgorithms[token.Method.Alg()] {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Method.Alg())
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return keySet.Key(kid, token.Method.Alg())
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+}
+
+// verifyIssuerAudience checks iss and aud against JWT_ISSUER and JWT_AUDIENCE, when they are set.
+func verifyIssuerAudience(claims jwt.MapClaims) error {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" && !claims.VerifyIssuer(issuer, true) {
+		return errors.New("invalid issuer")
+	}
+
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" && !claims.VerifyAudience(audience, true) {
+		return errors.New("invalid audience")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func encodeBigInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func TestParseTokenSigningAlgorithms(t *testing.T) {
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("JWT_AUDIENCE", "")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error: %v", err)
+	}
+
+	rsaJWK := jwk{Kty: "RSA", N: encodeBigInt(rsaKey.N), E: encodeBigInt(big.NewInt(int64(rsaKey.E)))}
+	ecJWK := jwk{Kty: "EC", Crv: "P-256", X: encodeBigInt(ecKey.X), Y: encodeBigInt(ecKey.Y)}
+
+	keys := []jwk{rsaJWK, rsaJWK, rsaJWK, ecJWK}
+	keys[0].Kid, keys[0].Alg = "rsa", "RS256"
+	keys[1].Kid, keys[1].Alg = "rsa-512", "RS512"
+	keys[2].Kid = "rsa-any"
+	keys[3].Kid = "ec"
+
+	data, err := json.Marshal(map[string][]jwk{"keys": keys})
+	if err != nil {
+		t.Fatalf("json.Marshal() error: %v", err)
+	}
+	source := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(source, data, 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error: %v", err)
+	}
+
+	jwks, err := NewJWKS(source)
+	if err != nil {
+		t.Fatalf("NewJWKS() error: %v", err)
+	}
+	UseJWKS(jwks)
+	t.Cleanup(func() {
+		UseJWKS(nil)
+	})
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		key    interface{}
+		valid  bool
+	}{
+		{"RS256", jwt.SigningMethodRS256, "rsa", rsaKey, true},
+		{"RS256 with a key without alg", jwt.SigningMethodRS256, "rsa-any", rsaKey, true},
+		{"ES256", jwt.SigningMethodES256, "ec", ecKey, true},
+		{"RS512 not allowed", jwt.SigningMethodRS512, "rsa-512", rsaKey, false},
+		{"RS384 not allowed", jwt.SigningMethodRS384, "rsa-any", rsaKey, false},
+		{"PS256 not allowed", jwt.SigningMethodPS256, "rsa-any", rsaKey, false},
+		{"RS256 with an RS512 key", jwt.SigningMethodRS256, "rsa-512", rsaKey, false},
+	}
+
+	now := time.Now().Unix()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(test.method, jwt.MapClaims{"sub": "a", "iat": now - 60, "exp": now + 3600})
+			token.Header["kid"] = test.kid
+
+			signed, err := token.SignedString(test.key)
+			if err != nil {
+				t.Fatalf("SignedString() error: %v", err)
+			}
+
+			_, err = ParseToken("Bearer " + signed)
+			if test.valid && err != nil {
+				t.Errorf("ParseToken() error: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("ParseToken() accepted a %s token signed with %q", test.method.Alg(), test.kid)
+			}
+		})
+	}
+}